@@ -10,9 +10,13 @@ import (
 
 	"github.com/ViBiOh/auth/pkg/auth"
 	"github.com/ViBiOh/dashboard/pkg/api"
+	"github.com/ViBiOh/dashboard/pkg/audit"
 	"github.com/ViBiOh/dashboard/pkg/deploy"
 	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/dashboard/pkg/registry"
 	"github.com/ViBiOh/dashboard/pkg/rollbar"
+	"github.com/ViBiOh/dashboard/pkg/secret"
+	"github.com/ViBiOh/dashboard/pkg/store"
 	"github.com/ViBiOh/dashboard/pkg/stream"
 	httputils "github.com/ViBiOh/httputils/pkg"
 	"github.com/ViBiOh/httputils/pkg/alcotest"
@@ -35,7 +39,7 @@ func handleGracefulClose(deployApp *deploy.App) error {
 	}
 
 	ticker := time.Tick(15 * time.Second)
-	timeout := time.After(deploy.DeployTimeout)
+	timeout := time.After(deploy.DeployTimeout + deploy.MaxStartPeriod)
 
 	for {
 		select {
@@ -64,7 +68,10 @@ func main() {
 	dockerConfig := docker.Flags(fs, "docker")
 	deployConfig := deploy.Flags(fs, "docker")
 	streamConfig := stream.Flags(fs, "docker")
+	storeConfig := store.Flags(fs, "store")
+	secretConfig := secret.Flags(fs, "secrets")
 	mailerConfig := client.Flags(fs, "mailer")
+	auditConfig := audit.Flags(fs, "audit")
 
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		logger.Fatal("%+v", err)
@@ -82,19 +89,42 @@ func main() {
 	corsApp := cors.New(corsConfig)
 
 	authApp := auth.New(authConfig)
-	dockerApp, err := docker.New(dockerConfig)
+	auditApp := audit.New(auditConfig)
+
+	storeApp, err := store.New(storeConfig)
+	if err != nil {
+		logger.Fatal("%+v", err)
+	}
+
+	dockerApp, err := docker.New(dockerConfig, storeApp, auditApp)
+	if err != nil {
+		logger.Fatal("%+v", err)
+	}
+
+	streamApp, err := stream.New(streamConfig, authApp, dockerApp, auditApp)
 	if err != nil {
 		logger.Fatal("%+v", err)
 	}
 
-	streamApp, err := stream.New(streamConfig, authApp, dockerApp)
+	secretApp, err := secret.New(secretConfig, storeApp, dockerApp, auditApp)
+	if err != nil {
+		logger.Fatal("%+v", err)
+	}
+
+	registryApp, err := registry.New(secretApp, auditApp)
 	if err != nil {
 		logger.Fatal("%+v", err)
 	}
 
 	mailerApp := client.New(mailerConfig)
-	deployApp := deploy.New(deployConfig, dockerApp, mailerApp)
-	apiApp := api.New(dockerApp, deployApp)
+	deployApp, err := deploy.New(deployConfig, dockerApp, mailerApp, registryApp, secretApp, storeApp, auditApp)
+	if err != nil {
+		logger.Fatal("%+v", err)
+	}
+
+	apiApp := api.New(dockerApp, deployApp, registryApp, secretApp, auditApp)
+
+	go dockerApp.ImageCollector()
 
 	restHandler := server.ChainMiddlewares(apiApp.Handler(), prometheusApp, opentracingApp, rollbarApp, gzipApp, owaspApp, corsApp, authApp)
 	websocketHandler := http.StripPrefix(websocketPrefix, streamApp.WebsocketHandler())
@@ -4,34 +4,55 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/ViBiOh/dashboard/pkg/audit"
 	"github.com/ViBiOh/dashboard/pkg/deploy"
 	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/dashboard/pkg/registry"
+	"github.com/ViBiOh/dashboard/pkg/secret"
 	"github.com/ViBiOh/httputils/pkg/httperror"
 )
 
 const (
+	auditPrefix      = "/audit"
 	containersPrefix = "/containers"
 	deployPrefix     = "/deploy"
+	imagesPrefix     = "/images"
+	quotaPrefix      = "/quota"
+	registriesPrefix = "/registries"
+	secretsPrefix    = "/secrets"
+	teamsPrefix      = "/teams"
 )
 
 // App of package
 type App struct {
-	dockerApp *docker.App
-	deployApp *deploy.App
+	auditApp    *audit.App
+	dockerApp   *docker.App
+	deployApp   *deploy.App
+	registryApp *registry.App
+	secretApp   *secret.App
 }
 
 // New creates new App
-func New(dockerApp *docker.App, deployApp *deploy.App) *App {
+func New(dockerApp *docker.App, deployApp *deploy.App, registryApp *registry.App, secretApp *secret.App, auditApp *audit.App) *App {
 	return &App{
-		dockerApp: dockerApp,
-		deployApp: deployApp,
+		auditApp:    auditApp,
+		dockerApp:   dockerApp,
+		deployApp:   deployApp,
+		registryApp: registryApp,
+		secretApp:   secretApp,
 	}
 }
 
 // Handler for Docker request. Should be use with net/http
 func (a App) Handler() http.Handler {
+	auditHandler := http.StripPrefix(auditPrefix, a.auditApp.Handler(docker.IsAdmin))
 	containerHandler := http.StripPrefix(containersPrefix, a.dockerApp.Handler())
 	deployHandler := http.StripPrefix(deployPrefix, a.deployApp.Handler())
+	imagesHandler := http.StripPrefix(imagesPrefix, a.dockerApp.ImagesHandler())
+	quotaHandler := http.StripPrefix(quotaPrefix, a.deployApp.QuotaHandler())
+	registryHandler := http.StripPrefix(registriesPrefix, a.registryApp.Handler())
+	secretHandler := http.StripPrefix(secretsPrefix, a.secretApp.Handler())
+	teamsHandler := http.StripPrefix(teamsPrefix, a.dockerApp.TeamsHandler())
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodOptions {
@@ -41,6 +62,11 @@ func (a App) Handler() http.Handler {
 			return
 		}
 
+		if strings.HasPrefix(r.URL.Path, auditPrefix) {
+			auditHandler.ServeHTTP(w, r)
+			return
+		}
+
 		if strings.HasPrefix(r.URL.Path, containersPrefix) {
 			containerHandler.ServeHTTP(w, r)
 			return
@@ -51,6 +77,31 @@ func (a App) Handler() http.Handler {
 			return
 		}
 
+		if strings.HasPrefix(r.URL.Path, imagesPrefix) {
+			imagesHandler.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, quotaPrefix) {
+			quotaHandler.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, registriesPrefix) {
+			registryHandler.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, secretsPrefix) {
+			secretHandler.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, teamsPrefix) {
+			teamsHandler.ServeHTTP(w, r)
+			return
+		}
+
 		httperror.NotFound(w)
 	})
 }
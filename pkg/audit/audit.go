@@ -0,0 +1,432 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/auth/pkg/auth"
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/httpjson"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/ViBiOh/httputils/pkg/tools"
+)
+
+const (
+	// Success result of an action
+	Success = "success"
+	// Failure result of an action
+	Failure = "failure"
+	// Denied result of a forbidden action
+	Denied = "denied"
+
+	defaultLimit = 100
+	maxMessage   = 1024
+	maxParams    = 32
+	maxLine      = 1 << 20
+)
+
+// ErrNotConfigured occurs when audit log is queried without file
+var ErrNotConfigured = errors.New("no audit file configured")
+
+// Entry of audit log
+type Entry struct {
+	Timestamp time.Time         `json:"timestamp"`
+	User      string            `json:"user"`
+	Action    string            `json:"action"`
+	App       string            `json:"app,omitempty"`
+	Container string            `json:"container,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+	Result    string            `json:"result"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// Config of package
+type Config struct {
+	file   *string
+	stdout *bool
+}
+
+// App of package
+type App struct {
+	file   string
+	stdout bool
+	mutex  sync.Mutex
+}
+
+// Flags adds flags for configuring package
+func Flags(fs *flag.FlagSet, prefix string) Config {
+	return Config{
+		file:   fs.String(tools.ToCamel(fmt.Sprintf("%sFile", prefix)), "", "[audit] Path to append-only audit log file"),
+		stdout: fs.Bool(tools.ToCamel(fmt.Sprintf("%sStdout", prefix)), false, "[audit] Mirror audit log to stdout as JSON lines"),
+	}
+}
+
+// New creates new App from Config
+func New(config Config) *App {
+	return &App{
+		file:   strings.TrimSpace(*config.file),
+		stdout: *config.stdout,
+	}
+}
+
+// NewEntry creates an entry for user's action, result being deduced from error
+func NewEntry(user *model.User, action string, err error) Entry {
+	entry := Entry{
+		Action: action,
+		Result: Success,
+	}
+
+	if user != nil {
+		entry.User = user.Username
+	}
+
+	if err != nil {
+		entry.Result = Failure
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
+
+func (a *App) enabled() bool {
+	return a != nil && (a.file != "" || a.stdout)
+}
+
+// Record appends entry to audit log
+func (a *App) Record(entry Entry) {
+	if !a.enabled() {
+		return
+	}
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry = truncateEntry(entry)
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		logger.Error("%+v", errors.WithStack(err))
+		return
+	}
+	payload = append(payload, '\n')
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.stdout {
+		if _, err := os.Stdout.Write(payload); err != nil {
+			logger.Error("%+v", errors.WithStack(err))
+		}
+	}
+
+	if a.file == "" {
+		return
+	}
+
+	if err := appendFile(a.file, payload); err != nil {
+		logger.Error("%+v", err)
+	}
+}
+
+func truncate(value string, size int) string {
+	if len(value) <= size {
+		return value
+	}
+
+	return fmt.Sprintf("%s...", value[:size])
+}
+
+func truncateEntry(entry Entry) Entry {
+	entry.Error = truncate(entry.Error, maxMessage)
+
+	if len(entry.Params) == 0 {
+		return entry
+	}
+
+	keys := make([]string, 0, len(entry.Params))
+	for key := range entry.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) > maxParams {
+		keys = keys[:maxParams]
+	}
+
+	params := make(map[string]string, len(keys))
+	for _, key := range keys {
+		params[truncate(key, maxMessage)] = truncate(entry.Params[key], maxMessage)
+	}
+	entry.Params = params
+
+	return entry
+}
+
+// RecordRequest runs handler and records entry with result of its response
+func (a *App) RecordRequest(w http.ResponseWriter, entry Entry, handler func(http.ResponseWriter)) {
+	if !a.enabled() {
+		handler(w)
+		return
+	}
+
+	writer := NewWriter(w)
+	handler(writer)
+
+	a.Record(writer.Complete(entry))
+}
+
+func appendFile(filename string, payload []byte) (err error) {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = errors.WithStack(closeErr)
+		}
+	}()
+
+	_, err = file.Write(payload)
+	return errors.WithStack(err)
+}
+
+type filter struct {
+	user      string
+	action    string
+	app       string
+	container string
+	result    string
+	since     time.Time
+	until     time.Time
+	limit     int
+}
+
+func parseFilter(params map[string][]string) (filter, error) {
+	get := func(name string) string {
+		if values := params[name]; len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+
+	output := filter{
+		user:      get("user"),
+		action:    get("action"),
+		app:       get("app"),
+		container: get("container"),
+		result:    get("result"),
+		limit:     defaultLimit,
+	}
+
+	var err error
+
+	if since := get("since"); since != "" {
+		if output.since, err = time.Parse(time.RFC3339, since); err != nil {
+			return output, errors.New("invalid since `%s`: %v", since, err)
+		}
+	}
+
+	if until := get("until"); until != "" {
+		if output.until, err = time.Parse(time.RFC3339, until); err != nil {
+			return output, errors.New("invalid until `%s`: %v", until, err)
+		}
+	}
+
+	if limit := get("limit"); limit != "" {
+		if output.limit, err = strconv.Atoi(limit); err != nil || output.limit <= 0 {
+			return output, errors.New("invalid limit `%s`", limit)
+		}
+	}
+
+	return output, nil
+}
+
+func (f filter) match(entry Entry) bool {
+	if f.user != "" && entry.User != f.user {
+		return false
+	}
+
+	if f.action != "" && entry.Action != f.action {
+		return false
+	}
+
+	if f.app != "" && entry.App != f.app {
+		return false
+	}
+
+	if f.container != "" && entry.Container != f.container {
+		return false
+	}
+
+	if f.result != "" && entry.Result != f.result {
+		return false
+	}
+
+	if !f.since.IsZero() && entry.Timestamp.Before(f.since) {
+		return false
+	}
+
+	if !f.until.IsZero() && entry.Timestamp.After(f.until) {
+		return false
+	}
+
+	return true
+}
+
+func (a *App) search(f filter) ([]Entry, error) {
+	if a.file == "" {
+		return nil, ErrNotConfigured
+	}
+
+	entries := make([]Entry, 0)
+
+	// Read without lock, not to delay records: entries are appended in a single write and an incomplete last line is skipped
+	file, err := os.Open(a.file)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			logger.Error("%+v", errors.WithStack(err))
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLine)
+
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+
+		if f.match(entry) {
+			entries = append(entries, entry)
+			if len(entries) > f.limit {
+				entries = entries[1:]
+			}
+		}
+	}
+
+	return entries, errors.WithStack(scanner.Err())
+}
+
+// Writer captures status and error message of a response
+type Writer struct {
+	http.ResponseWriter
+	status  int
+	message bytes.Buffer
+}
+
+// NewWriter wraps given ResponseWriter
+func NewWriter(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w}
+}
+
+// WriteHeader captures status
+func (w *Writer) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write captures beginning of error message
+func (w *Writer) Write(content []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if w.status >= http.StatusBadRequest && w.message.Len() < maxMessage {
+		w.message.Write(content)
+	}
+
+	return w.ResponseWriter.Write(content)
+}
+
+// Err returns error of response, nil if succeeded
+func (w *Writer) Err() error {
+	if w.status < http.StatusBadRequest {
+		return nil
+	}
+
+	message := strings.TrimSpace(w.message.String())
+	if message == "" {
+		message = http.StatusText(w.status)
+	}
+
+	return errors.New("%d %s", w.status, message)
+}
+
+// Complete sets result of entry from response
+func (w *Writer) Complete(entry Entry) Entry {
+	entry.Result = Success
+	entry.Error = ""
+
+	if err := w.Err(); err != nil {
+		entry.Result = Failure
+		entry.Error = err.Error()
+	}
+
+	if w.status == http.StatusForbidden {
+		entry.Result = Denied
+	}
+
+	return entry
+}
+
+// Handler for audit request, restricted to admins. Should be use with net/http
+func (a *App) Handler(isAdmin func(*model.User) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			httperror.BadRequest(w, errors.New("user not provided"))
+			return
+		}
+
+		if !isAdmin(user) {
+			httperror.Forbidden(w)
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.URL.Path != "/" && r.URL.Path != "" {
+			httperror.NotFound(w)
+			return
+		}
+
+		f, err := parseFilter(r.URL.Query())
+		if err != nil {
+			httperror.BadRequest(w, err)
+			return
+		}
+
+		entries, err := a.search(f)
+		if err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		if err := httpjson.ResponseArrayJSON(w, http.StatusOK, entries, httpjson.IsPretty(r)); err != nil {
+			httperror.InternalServerError(w, err)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/auth/pkg/model"
+)
+
+func TestNewEntry(t *testing.T) {
+	var cases = []struct {
+		intention string
+		user      *model.User
+		err       error
+		want      Entry
+	}{
+		{
+			"should handle nil user",
+			nil,
+			nil,
+			Entry{Action: "start", Result: Success},
+		},
+		{
+			"should set success without error",
+			model.NewUser("0", "vibioh", "", ""),
+			nil,
+			Entry{User: "vibioh", Action: "start", Result: Success},
+		},
+		{
+			"should set failure with error",
+			model.NewUser("0", "vibioh", "", ""),
+			errors.New("boom"),
+			Entry{User: "vibioh", Action: "start", Result: Failure, Error: "boom"},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := NewEntry(testCase.user, "start", testCase.err); result.User != testCase.want.User || result.Action != testCase.want.Action || result.Result != testCase.want.Result || result.Error != testCase.want.Error {
+			t.Errorf("%s\nNewEntry(%+v, start, %v) = %+v, want %+v", testCase.intention, testCase.user, testCase.err, result, testCase.want)
+		}
+	}
+}
+
+func TestWriterComplete(t *testing.T) {
+	var cases = []struct {
+		intention string
+		handler   func(http.ResponseWriter)
+		want      Entry
+	}{
+		{
+			"should set success on no content",
+			func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			Entry{Action: "set_secret", Result: Success},
+		},
+		{
+			"should set failure with response message",
+			func(w http.ResponseWriter) {
+				http.Error(w, "secret value is required", http.StatusBadRequest)
+			},
+			Entry{Action: "set_secret", Result: Failure, Error: "400 secret value is required"},
+		},
+		{
+			"should set denied on forbidden",
+			func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			Entry{Action: "set_secret", Result: Denied, Error: "403 Forbidden"},
+		},
+	}
+
+	for _, testCase := range cases {
+		writer := NewWriter(httptest.NewRecorder())
+		testCase.handler(writer)
+
+		if result := writer.Complete(Entry{Action: "set_secret"}); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\nComplete() = %+v, want %+v", testCase.intention, result, testCase.want)
+		}
+	}
+}
+
+func TestTruncateEntry(t *testing.T) {
+	params := make(map[string]string)
+	for i := 0; i < maxParams+10; i++ {
+		params[fmt.Sprintf("param%03d", i)] = strings.Repeat("a", maxMessage*2)
+	}
+
+	result := truncateEntry(Entry{Error: strings.Repeat("e", maxMessage*10), Params: params})
+
+	if len(result.Error) != maxMessage+3 {
+		t.Errorf("truncateEntry() error length = %d, want %d", len(result.Error), maxMessage+3)
+	}
+
+	if len(result.Params) != maxParams {
+		t.Errorf("truncateEntry() params count = %d, want %d", len(result.Params), maxParams)
+	}
+
+	for key, value := range result.Params {
+		if len(value) != maxMessage+3 {
+			t.Errorf("truncateEntry() param %s length = %d, want %d", key, len(value), maxMessage+3)
+		}
+	}
+
+	if len(params) != maxParams+10 {
+		t.Errorf("truncateEntry() modified given params")
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	var cases = []struct {
+		intention string
+		params    map[string][]string
+		want      filter
+		wantErr   string
+	}{
+		{
+			"should use default limit",
+			nil,
+			filter{limit: defaultLimit},
+			"",
+		},
+		{
+			"should parse all params",
+			map[string][]string{
+				"user":   {"vibioh"},
+				"action": {"deploy"},
+				"app":    {"dashboard"},
+				"result": {"denied"},
+				"since":  {"2019-01-01T00:00:00Z"},
+				"limit":  {"10"},
+			},
+			filter{user: "vibioh", action: "deploy", app: "dashboard", result: "denied", since: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), limit: 10},
+			"",
+		},
+		{
+			"should reject invalid date",
+			map[string][]string{"until": {"yesterday"}},
+			filter{},
+			"invalid until `yesterday`",
+		},
+		{
+			"should reject negative limit",
+			map[string][]string{"limit": {"-1"}},
+			filter{},
+			"invalid limit `-1`",
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := parseFilter(testCase.params)
+
+		failed := false
+
+		if testCase.wantErr != "" {
+			failed = err == nil || !strings.HasPrefix(err.Error(), testCase.wantErr)
+		} else if err != nil || result != testCase.want {
+			failed = true
+		}
+
+		if failed {
+			t.Errorf("%s\nparseFilter(%+v) = (%+v, %v), want (%+v, %s)", testCase.intention, testCase.params, result, err, testCase.want, testCase.wantErr)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Now()
+	entry := Entry{Timestamp: now, User: "vibioh", Action: "stop", App: "dashboard", Container: "abcd", Result: Success}
+
+	var cases = []struct {
+		intention string
+		filter    filter
+		want      bool
+	}{
+		{
+			"should match empty filter",
+			filter{},
+			true,
+		},
+		{
+			"should match all criterias",
+			filter{user: "vibioh", action: "stop", app: "dashboard", container: "abcd", result: Success, since: now.Add(-time.Minute), until: now.Add(time.Minute)},
+			true,
+		},
+		{
+			"should not match other user",
+			filter{user: "admin"},
+			false,
+		},
+		{
+			"should not match other result",
+			filter{result: Denied},
+			false,
+		},
+		{
+			"should not match older entry",
+			filter{since: now.Add(time.Minute)},
+			false,
+		},
+		{
+			"should not match newer entry",
+			filter{until: now.Add(-time.Minute)},
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := testCase.filter.match(entry); result != testCase.want {
+			t.Errorf("%s\nmatch(%+v) = %t, want %t", testCase.intention, testCase.filter, result, testCase.want)
+		}
+	}
+}
+
+func TestRecordAndSearch(t *testing.T) {
+	directory, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	app := &App{file: path.Join(directory, "audit.log")}
+
+	if result, err := app.search(filter{limit: defaultLimit}); err != nil || len(result) != 0 {
+		t.Errorf("search() on missing file = (%+v, %v), want ([], nil)", result, err)
+	}
+
+	if err := appendFile(app.file, []byte(fmt.Sprintf("{\"action\": \"%s\n", strings.Repeat("a", 100000)))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range []string{"start", "stop", "start", "restart"} {
+		app.Record(Entry{User: "vibioh", Action: action, Result: Success, Params: map[string]string{"compose": strings.Repeat("c", 100000)}})
+	}
+
+	var cases = []struct {
+		intention string
+		filter    filter
+		want      []string
+	}{
+		{
+			"should return all entries in order",
+			filter{limit: defaultLimit},
+			[]string{"start", "stop", "start", "restart"},
+		},
+		{
+			"should filter by action",
+			filter{action: "start", limit: defaultLimit},
+			[]string{"start", "start"},
+		},
+		{
+			"should keep latest entries within limit",
+			filter{limit: 2},
+			[]string{"start", "restart"},
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := app.search(testCase.filter)
+
+		actions := make([]string, 0, len(result))
+		for _, entry := range result {
+			if entry.Timestamp.IsZero() {
+				t.Errorf("%s\nsearch(%+v) returned entry without timestamp", testCase.intention, testCase.filter)
+			}
+			actions = append(actions, entry.Action)
+		}
+
+		if err != nil || !reflect.DeepEqual(actions, testCase.want) {
+			t.Errorf("%s\nsearch(%+v) = (%+v, %v), want %+v", testCase.intention, testCase.filter, actions, err, testCase.want)
+		}
+	}
+
+	if _, err := (&App{}).search(filter{}); err != ErrNotConfigured {
+		t.Errorf("search() without file = %v, want %v", err, ErrNotConfigured)
+	}
+}
@@ -6,9 +6,27 @@ const (
 	// OwnerLabel mark owner of stack
 	OwnerLabel = "owner"
 
+	// TeamLabel mark team sharing stack
+	TeamLabel = "team"
+
 	// AppLabel mark name of stack
 	AppLabel = "app"
 
+	// ServiceLabel mark name of service in stack
+	ServiceLabel = "service"
+
+	// ImageLabel mark image name, with tag, requested for container
+	ImageLabel = "image"
+
+	// TagLabel mark effective tag of container's image
+	TagLabel = "tag"
+
+	// DigestLabel mark resolved digest of container's image
+	DigestLabel = "digest"
+
+	// SecretsLabel mark environment variables filled with secrets
+	SecretsLabel = "secrets"
+
 	// IgnoredByteLogSize number of bytes ignored for logs
 	IgnoredByteLogSize = 8
 )
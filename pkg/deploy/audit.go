@@ -0,0 +1,78 @@
+package deploy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/audit"
+)
+
+const (
+	transferAction        = "transfer"
+	setVariablesAction    = "set_variables"
+	deleteVariablesAction = "delete_variables"
+	setEnvAction          = "set_env"
+	deleteEnvAction       = "delete_env"
+	redactedParam         = "********"
+)
+
+func getAuditParams(params url.Values) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+
+	output := make(map[string]string, len(params))
+	for key, values := range params {
+		if strings.HasPrefix(key, variableParamPrefix) {
+			output[key] = redactedParam
+		} else if len(values) > 0 {
+			output[key] = values[0]
+		}
+	}
+
+	return output
+}
+
+func (a *App) recordAction(user *model.User, action, appName string, params map[string]string, err error) {
+	entry := audit.NewEntry(user, action, err)
+	entry.App = appName
+	entry.Params = params
+
+	a.auditApp.Record(entry)
+}
+
+func (a *App) auditRequest(w http.ResponseWriter, r *http.Request, user *model.User, action, appName string, handler func(http.ResponseWriter)) {
+	writer := audit.NewWriter(w)
+	handler(writer)
+
+	if writer.Err() == nil {
+		return
+	}
+
+	entry := audit.Entry{
+		User:   user.Username,
+		Action: action,
+		App:    appName,
+		Params: getAuditParams(r.URL.Query()),
+	}
+
+	a.auditApp.Record(writer.Complete(entry))
+}
+
+func (a *App) recordChange(w http.ResponseWriter, r *http.Request, user *model.User, setAction, deleteAction, appName string, params map[string]string, handler func(http.ResponseWriter)) {
+	var action string
+
+	switch r.Method {
+	case http.MethodPut:
+		action = setAction
+	case http.MethodDelete:
+		action = deleteAction
+	default:
+		handler(w)
+		return
+	}
+
+	a.auditApp.RecordRequest(w, audit.Entry{User: user.Username, Action: action, App: appName, Params: params}, handler)
+}
@@ -1,20 +1,24 @@
 package deploy
 
 import (
-	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ViBiOh/auth/pkg/auth"
 	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/audit"
 	"github.com/ViBiOh/dashboard/pkg/commons"
 	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/dashboard/pkg/registry"
+	"github.com/ViBiOh/dashboard/pkg/secret"
+	"github.com/ViBiOh/dashboard/pkg/store"
 	"github.com/ViBiOh/httputils/pkg/errors"
 	"github.com/ViBiOh/httputils/pkg/httperror"
 	"github.com/ViBiOh/httputils/pkg/httpjson"
@@ -23,7 +27,9 @@ import (
 	"github.com/ViBiOh/httputils/pkg/tools"
 	"github.com/ViBiOh/mailer/pkg/client"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
 	opentracing "github.com/opentracing/opentracing-go"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -32,57 +38,151 @@ const (
 	// DeployTimeout indicates delay for application to deploy before rollback
 	DeployTimeout = 3 * time.Minute
 
+	// MaxStartPeriod indicates maximum healthcheck start period, extending deploy timeout
+	MaxStartPeriod = 5 * time.Minute
+
 	defaultCPUShares = 128
 	minMemory        = 16777216
 	maxMemory        = 805306368
 	colonSeparator   = ":"
 	deploySuffix     = "_deploy"
+
+	defaultStopGracePeriod = time.Minute
+	maxStopGracePeriod     = 5 * time.Minute
+
+	defaultRestartRetries = 5
+	defaultLogDriver      = "json-file"
+	defaultLogMaxSize     = "10m"
 )
 
+var redeployRequest = regexp.MustCompile(`^/([^/]+)/redeploy/?$`)
+
 // Config of package
 type Config struct {
-	network       *string
-	tag           *string
-	containerUser *string
-	appURL        *string
-	notification  *string
+	network        *string
+	tag            *string
+	containerUser  *string
+	appURL         *string
+	notification   *string
+	imagePolicy    *string
+	workers        *uint
+	configMaxSize  *uint
+	logDrivers     *string
+	sharedNetworks *string
+	domains        *string
+	traefikVersion *string
+	exposeDomain   *string
+	reservedApps   *string
+	quotas         *string
 }
 
 // App of package
 type App struct {
-	tasks         sync.Map
-	dockerApp     *docker.App
-	mailerApp     *client.App
-	network       string
-	tag           string
-	containerUser string
-	appURL        string
-	notification  string
+	tasks            sync.Map
+	envsMutex        sync.Mutex
+	hostnamesMutex   sync.Mutex
+	pendingHostnames map[string]hostnameClaim
+	quotasMutex      sync.Mutex
+	pendingUsages    map[string]pendingUsage
+	dockerApp        *docker.App
+	mailerApp        *client.App
+	registryApp      *registry.App
+	secretApp        *secret.App
+	storeApp         *store.App
+	auditApp         *audit.App
+	network          string
+	tag              string
+	containerUser    string
+	appURL           string
+	notification     string
+	imagePolicies    []imagePolicy
+	workers          uint
+	configMaxSize    uint
+	logDrivers       []string
+	sharedNetworks   []string
+	domains          map[string][]string
+	traefikVersion   string
+	exposeDomain     string
+	reservedApps     []string
+	quotas           []quota
 }
 
 // Flags adds flags for configuring package
 func Flags(fs *flag.FlagSet, prefix string) Config {
 	return Config{
-		network:       fs.String(tools.ToCamel(fmt.Sprintf("%sNetwork", prefix)), "traefik", "[deploy] Default Network"),
-		tag:           fs.String(tools.ToCamel(fmt.Sprintf("%sTag", prefix)), "latest", "[deploy] Default image tag)"),
-		containerUser: fs.String(tools.ToCamel(fmt.Sprintf("%sContainerUser", prefix)), "1000", "[deploy] Default container user"),
-		appURL:        fs.String(tools.ToCamel(fmt.Sprintf("%sAppURL", prefix)), "https://dashboard.vibioh.fr", "[deploy] Application web URL"),
-		notification:  fs.String(tools.ToCamel(fmt.Sprintf("%sNotification", prefix)), "onError", "[deploy] Send email notification when deploy ends (possibles values ares 'never', 'onError', 'all')"),
+		network:        fs.String(tools.ToCamel(fmt.Sprintf("%sNetwork", prefix)), "traefik", "[deploy] Default Network"),
+		tag:            fs.String(tools.ToCamel(fmt.Sprintf("%sTag", prefix)), "latest", "[deploy] Default image tag)"),
+		containerUser:  fs.String(tools.ToCamel(fmt.Sprintf("%sContainerUser", prefix)), "1000", "[deploy] Default container user"),
+		appURL:         fs.String(tools.ToCamel(fmt.Sprintf("%sAppURL", prefix)), "https://dashboard.vibioh.fr", "[deploy] Application web URL"),
+		notification:   fs.String(tools.ToCamel(fmt.Sprintf("%sNotification", prefix)), "onError", "[deploy] Send email notification when deploy ends (possibles values ares 'never', 'onError', 'all')"),
+		imagePolicy:    fs.String(tools.ToCamel(fmt.Sprintf("%sImagePolicy", prefix)), "", "[deploy] Path to image policy JSON file"),
+		workers:        fs.Uint(tools.ToCamel(fmt.Sprintf("%sWorkers", prefix)), 4, "[deploy] Number of services pulled and created concurrently"),
+		configMaxSize:  fs.Uint(tools.ToCamel(fmt.Sprintf("%sConfigMaxSize", prefix)), 256<<10, "[deploy] Maximum size in bytes of a config file"),
+		logDrivers:     fs.String(tools.ToCamel(fmt.Sprintf("%sLogDrivers", prefix)), "json-file,local", "[deploy] Allowed logging drivers, comma separated"),
+		sharedNetworks: fs.String(tools.ToCamel(fmt.Sprintf("%sSharedNetworks", prefix)), "", "[deploy] Shared networks services can join, comma separated"),
+		domains:        fs.String(tools.ToCamel(fmt.Sprintf("%sDomains", prefix)), "", "[deploy] Path to JSON file of domains patterns allowed per user"),
+		traefikVersion: fs.String(tools.ToCamel(fmt.Sprintf("%sTraefikVersion", prefix)), traefikV1, "[deploy] Traefik version of generated x-expose labels (possibles values are '1', '2')"),
+		exposeDomain:   fs.String(tools.ToCamel(fmt.Sprintf("%sExposeDomain", prefix)), "vibioh.fr", "[deploy] Domain of default x-expose hostname"),
+		reservedApps:   fs.String(tools.ToCamel(fmt.Sprintf("%sReservedApps", prefix)), "dashboard", "[deploy] App names reserved to admins, comma separated"),
+		quotas:         fs.String(tools.ToCamel(fmt.Sprintf("%sQuotas", prefix)), "", "[deploy] Path to quotas JSON file"),
 	}
 }
 
 // New creates new App from Config
-func New(config Config, dockerApp *docker.App, mailerApp *client.App) *App {
-	return &App{
-		tasks:         sync.Map{},
-		dockerApp:     dockerApp,
-		mailerApp:     mailerApp,
-		network:       *config.network,
-		tag:           *config.tag,
-		containerUser: *config.containerUser,
-		appURL:        *config.appURL,
-		notification:  *config.notification,
+func New(config Config, dockerApp *docker.App, mailerApp *client.App, registryApp *registry.App, secretApp *secret.App, storeApp *store.App, auditApp *audit.App) (*App, error) {
+	imagePolicies, err := loadImagePolicies(*config.imagePolicy)
+	if err != nil {
+		return nil, err
 	}
+
+	domains, err := loadDomains(*config.domains)
+	if err != nil {
+		return nil, err
+	}
+
+	quotas, err := loadQuotas(*config.quotas)
+	if err != nil {
+		return nil, err
+	}
+
+	traefikVersion := strings.TrimSpace(*config.traefikVersion)
+	if traefikVersion != traefikV1 && traefikVersion != traefikV2 {
+		return nil, errors.New("unknown traefik version %s", traefikVersion)
+	}
+
+	workers := *config.workers
+	if workers == 0 {
+		workers = 1
+	}
+
+	app := &App{
+		tasks:          sync.Map{},
+		dockerApp:      dockerApp,
+		mailerApp:      mailerApp,
+		registryApp:    registryApp,
+		secretApp:      secretApp,
+		storeApp:       storeApp,
+		auditApp:       auditApp,
+		network:        *config.network,
+		tag:            *config.tag,
+		containerUser:  *config.containerUser,
+		appURL:         *config.appURL,
+		notification:   *config.notification,
+		imagePolicies:  imagePolicies,
+		workers:        workers,
+		configMaxSize:  *config.configMaxSize,
+		logDrivers:     splitList(*config.logDrivers),
+		sharedNetworks: splitList(*config.sharedNetworks),
+		domains:        domains,
+		traefikVersion: traefikVersion,
+		exposeDomain:   strings.Trim(strings.TrimSpace(*config.exposeDomain), "."),
+		reservedApps:   splitList(*config.reservedApps),
+		quotas:         quotas,
+	}
+
+	dockerApp.OnAppRemoved(app.releaseHostnames)
+
+	return app, nil
 }
 
 // CanBeGracefullyClosed indicates if application can terminate safely
@@ -97,12 +197,17 @@ func (a *App) CanBeGracefullyClosed() (canBe bool) {
 	return
 }
 
-func (a *App) pullImage(ctx context.Context, image string) error {
+func (a *App) pullImage(ctx context.Context, user *model.User, appName string, image string) error {
 	if !strings.Contains(image, colonSeparator) {
 		image = fmt.Sprintf("%s%slatest", image, colonSeparator)
 	}
 
-	pull, err := a.dockerApp.Docker.ImagePull(ctx, image, types.ImagePullOptions{})
+	registryAuth, err := a.registryApp.Auth(user, appName, image)
+	if err != nil {
+		return err
+	}
+
+	pull, err := a.dockerApp.Docker.ImagePull(ctx, image, types.ImagePullOptions{RegistryAuth: registryAuth})
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -111,15 +216,34 @@ func (a *App) pullImage(ctx context.Context, image string) error {
 	return err
 }
 
+func (a *App) inspectImage(ctx context.Context, image string) (types.ImageInspect, error) {
+	infos, _, err := a.dockerApp.Docker.ImageInspectWithRaw(ctx, image)
+	return infos, errors.WithStack(err)
+}
+
+func (a *App) getContainerStopGracePeriod(ctx context.Context, containerID string) time.Duration {
+	infos, err := a.dockerApp.InspectContainer(ctx, containerID)
+	if err != nil {
+		logger.Error("cannot inspect container %s: %+v", containerID, err)
+		return defaultStopGracePeriod
+	}
+
+	if infos.Config == nil || infos.Config.StopTimeout == nil {
+		return defaultStopGracePeriod
+	}
+
+	return time.Duration(*infos.Config.StopTimeout) * time.Second
+}
+
 func (a *App) cleanContainers(ctx context.Context, containers []types.Container) error {
 	for _, container := range containers {
-		if _, err := a.dockerApp.GracefulStopContainer(ctx, container.ID, time.Minute); err != nil {
+		if _, err := a.dockerApp.GracefulStopContainer(ctx, container.ID, a.getContainerStopGracePeriod(ctx, container.ID)); err != nil {
 			logger.Error("cannot stop container %s: %+v", container.Names, err)
 		}
 	}
 
 	for _, container := range containers {
-		if _, err := a.dockerApp.RmContainer(ctx, container.ID, nil, false); err != nil {
+		if _, err := a.dockerApp.RmContainer(ctx, container.ID, nil); err != nil {
 			return err
 		}
 	}
@@ -147,7 +271,7 @@ func (a *App) deleteServices(ctx context.Context, appName string, services map[s
 				logger.Error("user=%s, app=%s service=%s %+v", user.Username, appName, service.Name, err)
 			}
 
-			if _, err := a.dockerApp.RmContainer(ctx, service.ContainerID, infos, true); err != nil {
+			if _, err := a.dockerApp.RmContainer(ctx, service.ContainerID, infos); err != nil {
 				logger.Error("user=%s, app=%s service=%s %+v", user.Username, appName, service.Name, err)
 			}
 		}
@@ -196,7 +320,7 @@ func (a *App) areContainersHealthy(ctx context.Context, user *model.User, appNam
 	filtersArgs := filters.NewArgs()
 	healthyStatusFilters(&filtersArgs, containersIdsWithHealthcheck)
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, DeployTimeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, getDeployTimeout(containersServices))
 	defer cancel()
 
 	messages, errs := a.dockerApp.Docker.Events(timeoutCtx, types.EventsOptions{Filters: filtersArgs})
@@ -222,9 +346,12 @@ func (a *App) areContainersHealthy(ctx context.Context, user *model.User, appNam
 	}
 }
 
-func (a *App) finishDeploy(ctx context.Context, user *model.User, appName string, services map[string]*deployedService, oldContainers []types.Container, requestParams url.Values) {
+func (a *App) finishDeploy(ctx context.Context, user *model.User, ownership docker.Ownership, appName string, composeFile []byte, files map[string][]byte, services map[string]*deployedService, oldContainers []types.Container, requestParams url.Values, startErr error) {
 	defer func() {
 		defer a.tasks.Delete(appName)
+		defer a.dockerApp.DeployEnded()
+		defer a.releasePendingHostnames(appName)
+		defer a.releaseQuota(appName)
 	}()
 
 	if span := opentracing.SpanFromContext(ctx); span != nil {
@@ -240,6 +367,9 @@ func (a *App) finishDeploy(ctx context.Context, user *model.User, appName string
 
 	if success {
 		logger.Info("user=%s, app=%s Successful deploy", user.Username, appName)
+		if startErr == nil {
+			a.recordAction(user, docker.DeployAction, appName, getAuditParams(requestParams), nil)
+		}
 
 		if err := a.cleanContainers(ctx, oldContainers); err != nil {
 			logger.Error("user=%s, app=%s %+v", user.Username, appName, err)
@@ -248,8 +378,25 @@ func (a *App) finishDeploy(ctx context.Context, user *model.User, appName string
 		if err := a.renameDeployedContainers(ctx, services); err != nil {
 			logger.Error("user=%s, app=%s %+v", user.Username, appName, err)
 		}
+
+		if a.storeApp.Enabled() {
+			if err := a.writeDeploy(ownership.Owner, appName, composeFile, files, getRequestVariables(requestParams)); err != nil {
+				logger.Error("user=%s, app=%s %+v", user.Username, appName, err)
+			}
+
+			if err := a.claimHostnames(ownership.Owner, appName, services); err != nil {
+				logger.Error("user=%s, app=%s %+v", user.Username, appName, err)
+			}
+
+			if err := a.dockerApp.SetAppOwnership(appName, ownership); err != nil {
+				logger.Error("user=%s, app=%s %+v", user.Username, appName, err)
+			}
+		}
 	} else {
 		logger.Warn("user=%s, app=%s %v", user.Username, appName, errHealthCheckFailed)
+		if startErr == nil {
+			a.recordAction(user, docker.DeployAction, appName, getAuditParams(requestParams), errHealthCheckFailed)
+		}
 		a.captureServicesHealth(ctx, user, appName, services)
 		a.deleteServices(ctx, appName, services, user)
 	}
@@ -270,133 +417,445 @@ func (a *App) finishDeploy(ctx context.Context, user *model.User, appName string
 	}
 }
 
-func (a *App) createContainer(ctx context.Context, user *model.User, appName string, serviceName string, service *dockerComposeService) (*deployedService, error) {
+func (a *App) createContainer(ctx context.Context, user *model.User, ownership docker.Ownership, appName string, serviceName string, service *dockerComposeService, configsFiles []containerFile, tag string, policy *imagePolicy, environment string) (*deployedService, error) {
+	secretsEnv, secretsNames, secretsFiles, err := a.getServiceSecrets(user, appName, service)
+	if err != nil {
+		return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
+	}
+
+	files := append(configsFiles, secretsFiles...)
+	if len(files) > 0 && service.ReadOnly != nil && *service.ReadOnly {
+		return nil, errors.New("user=%s, app=%s service=%s configs and secrets files cannot be written in a read_only service", user.Username, appName, serviceName)
+	}
+
 	imagePulled := false
 
-	if a.tag != "" {
-		imageOverride := fmt.Sprintf("%s%s%s", service.Image, colonSeparator, a.tag)
-		if err := a.pullImage(ctx, imageOverride); err == nil {
-			service.Image = imageOverride
-			imagePulled = true
+	if tag != "" {
+		imageOverride, err := getImageOverride(service.Image, tag)
+		if err != nil {
+			return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
+		}
+
+		if imageOverride != "" {
+			if err := policy.checkReference(imageOverride, environment); err != nil {
+				logger.Info("user=%s, app=%s service=%s tag override skipped: %v", user.Username, appName, serviceName, err)
+			} else if err := a.pullImage(ctx, user, appName, imageOverride); err == nil {
+				service.Image = imageOverride
+				imagePulled = true
+			}
 		}
 	}
 
 	if !imagePulled {
-		if err := a.pullImage(ctx, service.Image); err != nil {
+		if err := policy.checkReference(service.Image, environment); err != nil {
+			return nil, errors.New("user=%s, app=%s service=%s rejected by image policy: %v", user.Username, appName, serviceName, err)
+		}
+
+		if err := a.pullImage(ctx, user, appName, service.Image); err != nil {
 			return nil, err
 		}
 	}
 
+	image, err := a.inspectImage(ctx, service.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := policy.checkImage(image); err != nil {
+		return nil, errors.New("user=%s, app=%s service=%s rejected by image policy: %v", user.Username, appName, serviceName, err)
+	}
+
+	pinnedImage, imageDigest := getRepoDigest(service.Image, image.RepoDigests)
+	imageTag := getImageTag(service.Image)
+
 	serviceFullName := getServiceFullName(appName, serviceName)
 
-	config, err := a.getConfig(service, user, appName)
+	config, err := a.getConfig(service, ownership, appName)
 	if err != nil {
 		return nil, err
 	}
 
-	createdContainer, err := a.dockerApp.Docker.ContainerCreate(ctx, config, a.getHostConfig(service, user), a.getNetworkConfig(serviceName, service), serviceFullName)
+	config.Labels[commons.ServiceLabel] = serviceName
+	config.Labels[commons.ImageLabel] = service.Image
+	config.Labels[commons.TagLabel] = imageTag
+	if imageDigest != "" {
+		config.Image = pinnedImage
+		config.Labels[commons.DigestLabel] = imageDigest
+	} else {
+		logger.Warn("user=%s, app=%s service=%s no repo digest found for %s", user.Username, appName, serviceName, service.Image)
+	}
+
+	if len(secretsEnv) > 0 {
+		config.Env = append(config.Env, secretsEnv...)
+		config.Labels[commons.SecretsLabel] = strings.Join(secretsNames, ",")
+	}
+
+	hostConfig, err := a.getHostConfig(service, user)
+	if err != nil {
+		return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
+	}
+
+	if policy != nil && !docker.IsAdmin(user) {
+		if err := policy.Hardening.apply(service, config, hostConfig); err != nil {
+			return nil, errors.New("user=%s, app=%s service=%s rejected by hardening profile: %v", user.Username, appName, serviceName, err)
+		}
+	}
+
+	primaryNetwork, endpoints, err := a.getNetworksConfig(user, appName, serviceName, service)
+	if err != nil {
+		return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
+	}
+
+	hostConfig.NetworkMode = container.NetworkMode(primaryNetwork)
+	if _, ok := endpoints[a.network]; ok && config.Labels[traefikNetworkLabel] == "" {
+		config.Labels[traefikNetworkLabel] = a.network
+	}
+
+	networkConfig := network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			primaryNetwork: endpoints[primaryNetwork],
+		},
+	}
+
+	createdContainer, err := a.dockerApp.Docker.ContainerCreate(ctx, config, hostConfig, &networkConfig, serviceFullName)
 	if err != nil {
 		return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
 	}
 
+	if err := a.prepareContainer(ctx, createdContainer.ID, primaryNetwork, endpoints, files); err != nil {
+		if _, rmErr := a.dockerApp.RmContainer(ctx, createdContainer.ID, nil); rmErr != nil {
+			logger.Error("user=%s, app=%s service=%s %+v", user.Username, appName, serviceName, rmErr)
+		}
+
+		return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
+	}
+
+	hostnames, err := getLabelsHostnames(service.Labels)
+	if err != nil {
+		logger.Warn("user=%s, app=%s service=%s hostnames not claimed: %v", user.Username, appName, serviceName, err)
+	}
+
 	return &deployedService{
 		Name:        serviceName,
 		FullName:    serviceFullName,
 		ContainerID: createdContainer.ID,
 		ImageName:   service.Image,
+		ImageDigest: imageDigest,
+		ImageTag:    imageTag,
+		hostnames:   hostnames,
 	}, nil
 }
 
-func (a *App) parseCompose(ctx context.Context, user *model.User, appName string, composeFile []byte) (newServices map[string]*deployedService, err error) {
-	composeFile = bytes.Replace(composeFile, []byte("$$"), []byte("$"), -1)
-
+func (a *App) parseCompose(ctx context.Context, user *model.User, ownership docker.Ownership, appName string, composeFile []byte, files map[string][]byte, variables map[string]string, tags tagOverrides, requestParams url.Values) (newServices map[string]*deployedService, err error) {
 	compose := dockerCompose{}
 	if err := yaml.Unmarshal(composeFile, &compose); err != nil {
 		return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
 	}
 
-	defer func() {
-		if err != nil {
-			for _, service := range newServices {
-				if _, rmErr := a.dockerApp.RmContainerAndImages(ctx, service.ContainerID, nil); rmErr != nil {
-					logger.Error("%+v", rmErr)
-				}
-			}
+	environment := strings.TrimSpace(requestParams.Get("environment"))
+	policy := getImagePolicy(a.imagePolicies, user)
+	if err := policy.checkServices(compose.Services, environment, tags); err != nil {
+		return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
+	}
+
+	if !docker.IsAdmin(user) {
+		if err := policy.checkServicesOptions(compose.Services); err != nil {
+			return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
 		}
-	}()
 
-	newServices = make(map[string]*deployedService)
+		if err := checkLinks(user, compose.Services, a.findContainerOwner(ctx, user)); err != nil {
+			return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
+		}
+	}
+
+	if err := a.exposeServices(appName, compose.Services); err != nil {
+		return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
+	}
+
+	if err := a.checkTraefikLabels(ctx, user, ownership, appName, compose.Services); err != nil {
+		return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
+	}
+
+	configsContent, err := getConfigsContent(compose.Configs, files, a.configMaxSize)
+	if err != nil {
+		return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
+	}
+
+	envFiles, err := a.getEnvFiles(user, appName, files)
+	if err != nil {
+		return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
+	}
+
+	configsFiles := make(map[string][]containerFile)
 	for serviceName, service := range compose.Services {
-		deployedService, createErr := a.createContainer(ctx, user, appName, serviceName, &service)
-		if createErr != nil {
-			err = createErr
-			return
+		if configsFiles[serviceName], err = getServiceConfigs(&service, configsContent); err != nil {
+			return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
+		}
+
+		if service.Environment, err = resolveEnvironment(&service, envFiles, variables); err != nil {
+			return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
+		}
+		compose.Services[serviceName] = service
+
+		if _, err = a.getServiceNetworks(user, &service); err != nil {
+			return nil, errors.New("user=%s, app=%s service=%s %v", user.Username, appName, serviceName, err)
 		}
+	}
 
-		newServices[serviceName] = deployedService
+	if usesAppNetwork(compose.Services) {
+		if _, err = a.dockerApp.CreateAppNetwork(ctx, user, appName); err != nil {
+			return nil, errors.New("user=%s, app=%s %v", user.Username, appName, err)
+		}
 	}
 
+	newServices, err = a.createContainers(ctx, user, ownership, appName, compose.Services, configsFiles, tags, policy, environment)
 	return
 }
 
+func (a *App) prepareContainer(ctx context.Context, containerID string, primaryNetwork string, endpoints map[string]*network.EndpointSettings, files []containerFile) error {
+	for name, endpoint := range endpoints {
+		if name == primaryNetwork {
+			continue
+		}
+
+		if err := a.dockerApp.Docker.NetworkConnect(ctx, name, containerID, endpoint); err != nil {
+			return errors.New("unable to connect to network %s: %v", name, err)
+		}
+	}
+
+	if err := a.copyFiles(ctx, containerID, files); err != nil {
+		return errors.New("unable to write files: %v", err)
+	}
+
+	return nil
+}
+
+func (a *App) createContainers(ctx context.Context, user *model.User, ownership docker.Ownership, appName string, services map[string]dockerComposeService, configsFiles map[string][]containerFile, tags tagOverrides, policy *imagePolicy, environment string) (map[string]*deployedService, error) {
+	createCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	newServices := make(map[string]*deployedService)
+	createErrors := make([]string, 0)
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, a.workers)
+
+	for serviceName, service := range services {
+		wg.Add(1)
+
+		go func(serviceName string, service dockerComposeService) {
+			defer wg.Done()
+
+			workers <- struct{}{}
+			defer func() {
+				<-workers
+			}()
+
+			if createCtx.Err() != nil {
+				return
+			}
+
+			deployedService, err := a.createContainer(createCtx, user, ownership, appName, serviceName, &service, configsFiles[serviceName], tags.get(serviceName), policy, environment)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				if len(createErrors) == 0 || createCtx.Err() == nil {
+					createErrors = append(createErrors, err.Error())
+				}
+
+				cancel()
+				return
+			}
+
+			newServices[serviceName] = deployedService
+		}(serviceName, service)
+	}
+
+	wg.Wait()
+
+	if len(createErrors) > 0 {
+		for _, service := range newServices {
+			if _, err := a.dockerApp.RmContainer(ctx, service.ContainerID, nil); err != nil {
+				logger.Error("user=%s, app=%s service=%s %+v", user.Username, appName, service.Name, err)
+			}
+		}
+
+		return nil, errors.New("%s", strings.Join(createErrors, ", "))
+	}
+
+	return newServices, nil
+}
+
+func (a *App) deploy(w http.ResponseWriter, r *http.Request, user *model.User, appName string, composeFile []byte, files map[string][]byte) {
+	if !a.dockerApp.Can(user, docker.DeployAction, appName) {
+		httperror.Forbidden(w)
+		return
+	}
+
+	a.dockerApp.DeployStarted()
+	finishing := false
+	defer func() {
+		if !finishing {
+			a.dockerApp.DeployEnded()
+			a.releasePendingHostnames(appName)
+			a.releaseQuota(appName)
+		}
+	}()
+
+	tags, err := getTagOverrides(r.URL.Query(), a.tag)
+	if err != nil {
+		httperror.BadRequest(w, err)
+		return
+	}
+
+	variables, err := a.getVariables(user, appName, r.URL.Query())
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	interpolatedCompose, err := interpolateCompose(composeFile, variables)
+	if err != nil {
+		httperror.BadRequest(w, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	oldContainers, ownership, err := a.checkRights(ctx, user, appName, strings.TrimSpace(r.URL.Query().Get(teamParam)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !a.reserveQuota(ctx, w, user, ownership.Owner, appName, interpolatedCompose) {
+		return
+	}
+
+	newServices, err := a.parseCompose(ctx, user, ownership, appName, interpolatedCompose, files, variables, tags, r.URL.Query())
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	if err = a.checkTasks(user, appName); err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	if err == nil {
+		err = a.startServices(ctx, newServices)
+	}
+
+	ctx = context.Background()
+	if span := opentracing.SpanFromContext(r.Context()); span != nil {
+		parentSpanContext := span.Context()
+		_, ctx = opentracing.StartSpanFromContext(ctx, "Deploy", opentracing.FollowsFrom(parentSpanContext))
+	}
+
+	finishing = true
+	go a.finishDeploy(ctx, user, ownership, appName, composeFile, files, newServices, oldContainers, r.URL.Query(), err)
+
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	if err := httpjson.ResponseArrayJSON(w, http.StatusOK, newServices, httpjson.IsPretty(r)); err != nil {
+		httperror.InternalServerError(w, err)
+	}
+}
+
+func (a *App) redeploy(w http.ResponseWriter, r *http.Request, user *model.User, appName string) {
+	if !a.isValidAppName(w, user, appName) {
+		return
+	}
+
+	composeFile, files, variables, err := a.readDeploy(a.dockerApp.GetAppOwner(user, appName), appName)
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	if len(composeFile) == 0 {
+		httperror.NotFound(w)
+		return
+	}
+
+	query := r.URL.Query()
+	for name, value := range variables {
+		if _, ok := query[variableParamPrefix+name]; !ok {
+			query.Set(variableParamPrefix+name, value)
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+
+	a.deploy(w, r, user, appName, composeFile, files)
+}
+
 // Handler for request. Should be use with net/http
 func (a *App) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
-
 		user := auth.UserFromContext(r.Context())
 		if user == nil {
 			httperror.BadRequest(w, errors.New("user not provided"))
 			return
 		}
 
-		appName, composeFile, err := checkParams(r, user)
-		if err != nil {
-			httperror.BadRequest(w, err)
+		if variablesRequest.MatchString(r.URL.Path) {
+			appName := variablesRequest.FindStringSubmatch(r.URL.Path)[1]
+			a.recordChange(w, r, user, setVariablesAction, deleteVariablesAction, appName, nil, func(writer http.ResponseWriter) {
+				a.variablesHandler(writer, r, user, appName)
+			})
 			return
 		}
 
-		ctx := r.Context()
-
-		oldContainers, err := a.checkRights(ctx, user, appName)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusForbidden)
+		if envsRequest.MatchString(r.URL.Path) {
+			a.envsHandler(w, r, user, envsRequest.FindStringSubmatch(r.URL.Path)[1])
 			return
 		}
 
-		newServices, err := a.parseCompose(ctx, user, appName, composeFile)
-		if err != nil {
-			httperror.InternalServerError(w, err)
+		if envRequest.MatchString(r.URL.Path) {
+			matches := envRequest.FindStringSubmatch(r.URL.Path)
+			a.recordChange(w, r, user, setEnvAction, deleteEnvAction, matches[1], map[string]string{"name": matches[2]}, func(writer http.ResponseWriter) {
+				a.envHandler(writer, r, user, matches[1], matches[2])
+			})
 			return
 		}
 
-		if err = a.checkTasks(user, appName); err != nil {
-			httperror.InternalServerError(w, err)
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		if err == nil {
-			err = a.startServices(ctx, newServices)
+		if transferRequest.MatchString(r.URL.Path) {
+			appName := transferRequest.FindStringSubmatch(r.URL.Path)[1]
+			a.auditRequest(w, r, user, transferAction, appName, func(writer http.ResponseWriter) {
+				a.transfer(writer, r, user, appName)
+			})
+			return
 		}
 
-		ctx = context.Background()
-		if span := opentracing.SpanFromContext(r.Context()); span != nil {
-			parentSpanContext := span.Context()
-			_, ctx = opentracing.StartSpanFromContext(ctx, "Deploy", opentracing.FollowsFrom(parentSpanContext))
+		if redeployRequest.MatchString(r.URL.Path) {
+			appName := redeployRequest.FindStringSubmatch(r.URL.Path)[1]
+			a.auditRequest(w, r, user, docker.DeployAction, appName, func(writer http.ResponseWriter) {
+				a.redeploy(writer, r, user, appName)
+			})
+			return
 		}
 
-		go a.finishDeploy(ctx, user, appName, newServices, oldContainers, r.URL.Query())
-
+		appName, composeFile, files, err := checkParams(r, user, a.reservedApps)
 		if err != nil {
-			httperror.InternalServerError(w, err)
+			a.recordAction(user, docker.DeployAction, appName, getAuditParams(r.URL.Query()), err)
+			httperror.BadRequest(w, err)
 			return
 		}
 
-		if err := httpjson.ResponseArrayJSON(w, http.StatusOK, newServices, httpjson.IsPretty(r)); err != nil {
-			httperror.InternalServerError(w, err)
-		}
+		a.auditRequest(w, r, user, docker.DeployAction, appName, func(writer http.ResponseWriter) {
+			a.deploy(writer, r, user, appName, composeFile, files)
+		})
 	})
 }
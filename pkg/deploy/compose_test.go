@@ -1,10 +1,27 @@
 package deploy
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
 )
 
 func TestGetServiceFullName(t *testing.T) {
@@ -84,3 +101,397 @@ func TestHealthyStatusFilters(t *testing.T) {
 		}
 	}
 }
+
+func TestGetRepoDigest(t *testing.T) {
+	var cases = []struct {
+		intention   string
+		image       string
+		repoDigests []string
+		want        string
+		wantDigest  string
+	}{
+		{
+			"should handle no digest",
+			"vibioh/dashboard:latest",
+			nil,
+			"",
+			"",
+		},
+		{
+			"should find digest of same repository",
+			"vibioh/dashboard:1.0.0",
+			[]string{"registry.vibioh.fr/vibioh/dashboard@sha256:c80455665c574e845529369fb21d5295711e7c9772e456cd161b64d6b9cfde67", "vibioh/dashboard@sha256:56f28e909d399cbf0b95d3270b94762206feebd90f0079a2a1606ff5faec92e0"},
+			"vibioh/dashboard@sha256:56f28e909d399cbf0b95d3270b94762206feebd90f0079a2a1606ff5faec92e0",
+			"sha256:56f28e909d399cbf0b95d3270b94762206feebd90f0079a2a1606ff5faec92e0",
+		},
+	}
+
+	for _, testCase := range cases {
+		if result, digest := getRepoDigest(testCase.image, testCase.repoDigests); result != testCase.want || digest != testCase.wantDigest {
+			t.Errorf("%s\ngetRepoDigest(%v, %v) = (%v, %v), want (%v, %v)", testCase.intention, testCase.image, testCase.repoDigests, result, digest, testCase.want, testCase.wantDigest)
+		}
+	}
+}
+
+func TestGetTagOverrides(t *testing.T) {
+	var cases = []struct {
+		intention  string
+		params     url.Values
+		defaultTag string
+		want       tagOverrides
+		wantErr    string
+	}{
+		{
+			"should use default tag",
+			url.Values{},
+			"latest",
+			tagOverrides{defaultTag: "latest", services: map[string]string{}},
+			"",
+		},
+		{
+			"should handle request and service tags",
+			url.Values{"tag": {"abc123"}, "tag.api": {"def456"}, "environment": {"prod"}},
+			"latest",
+			tagOverrides{defaultTag: "abc123", services: map[string]string{"api": "def456"}},
+			"",
+		},
+		{
+			"should reject invalid tag",
+			url.Values{"tag.api": {"../root"}},
+			"",
+			tagOverrides{},
+			"invalid tag `../root` for tag.api",
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := getTagOverrides(testCase.params, testCase.defaultTag)
+
+		if testCase.wantErr != "" {
+			if err == nil || err.Error() != testCase.wantErr {
+				t.Errorf("%s\ngetTagOverrides(%v, %v) = %v, want error %v", testCase.intention, testCase.params, testCase.defaultTag, err, testCase.wantErr)
+			}
+		} else if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetTagOverrides(%v, %v) = (%v, %v), want %v", testCase.intention, testCase.params, testCase.defaultTag, result, err, testCase.want)
+		}
+	}
+}
+
+func TestGetImageOverride(t *testing.T) {
+	var cases = []struct {
+		image string
+		tag   string
+		want  string
+	}{
+		{
+			"vibioh/dashboard",
+			"abc123",
+			"vibioh/dashboard:abc123",
+		},
+		{
+			"registry.vibioh.fr:5000/dashboard",
+			"abc123",
+			"registry.vibioh.fr:5000/dashboard:abc123",
+		},
+		{
+			"vibioh/dashboard:1.0.0",
+			"abc123",
+			"",
+		},
+	}
+
+	for _, testCase := range cases {
+		if result, err := getImageOverride(testCase.image, testCase.tag); result != testCase.want || err != nil {
+			t.Errorf("getImageOverride(%v, %v) = (%v, %v), want %v", testCase.image, testCase.tag, result, err, testCase.want)
+		}
+	}
+}
+
+func TestGetImageTag(t *testing.T) {
+	var cases = []struct {
+		image string
+		want  string
+	}{
+		{
+			"vibioh/dashboard",
+			"latest",
+		},
+		{
+			"vibioh/dashboard:abc123",
+			"abc123",
+		},
+		{
+			"vibioh/dashboard@sha256:56f28e909d399cbf0b95d3270b94762206feebd90f0079a2a1606ff5faec92e0",
+			"",
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := getImageTag(testCase.image); result != testCase.want {
+			t.Errorf("getImageTag(%v) = %v, want %v", testCase.image, result, testCase.want)
+		}
+	}
+}
+
+func TestShellSplit(t *testing.T) {
+	var cases = []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			"",
+			[]string{},
+			false,
+		},
+		{
+			"  /bin/api  -port 1080 ",
+			[]string{"/bin/api", "-port", "1080"},
+			false,
+		},
+		{
+			`sh -c 'echo "$HOME" && exit 0'`,
+			[]string{"sh", "-c", `echo "$HOME" && exit 0`},
+			false,
+		},
+		{
+			`echo "a \"quoted\" \n value" an\ escaped ''`,
+			[]string{"echo", `a "quoted" \n value`, "an escaped", ""},
+			false,
+		},
+		{
+			`echo "unterminated`,
+			nil,
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := shellSplit(testCase.value)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("shellSplit(%#v) = %#v, want error", testCase.value, result)
+			}
+		} else if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("shellSplit(%#v) = (%#v, %v), want (%#v, nil)", testCase.value, result, err, testCase.want)
+		}
+	}
+}
+
+func TestGetDeployTimeout(t *testing.T) {
+	var cases = []struct {
+		intention  string
+		containers []*types.ContainerJSON
+		want       time.Duration
+	}{
+		{
+			"should use default timeout without start period",
+			[]*types.ContainerJSON{
+				{Config: &container.Config{}},
+			},
+			DeployTimeout,
+		},
+		{
+			"should extend timeout with longest start period",
+			[]*types.ContainerJSON{
+				{Config: &container.Config{Healthcheck: &container.HealthConfig{Test: []string{"CMD", "/healthcheck"}, StartPeriod: time.Minute}}},
+				{Config: &container.Config{Healthcheck: &container.HealthConfig{Test: []string{"CMD", "/healthcheck"}, StartPeriod: 2 * time.Minute}}},
+				{Config: &container.Config{Healthcheck: &container.HealthConfig{Test: []string{"NONE"}, StartPeriod: 4 * time.Minute}}},
+			},
+			DeployTimeout + 2*time.Minute,
+		},
+		{
+			"should cap start period",
+			[]*types.ContainerJSON{
+				{Config: &container.Config{Healthcheck: &container.HealthConfig{Test: []string{"CMD", "/healthcheck"}, StartPeriod: time.Hour}}},
+			},
+			DeployTimeout + MaxStartPeriod,
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := getDeployTimeout(testCase.containers); result != testCase.want {
+			t.Errorf("%s\ngetDeployTimeout(%+v) = %v, want %v", testCase.intention, testCase.containers, result, testCase.want)
+		}
+	}
+}
+
+func TestWriteReadDeploy(t *testing.T) {
+	app, clean := newTestApp(t, "[]")
+	defer clean()
+
+	composeFile := []byte("version: '3'\nservices:\n  web:\n    image: nginx:${TAG:?}\n")
+	files := map[string][]byte{"nginx.conf": []byte("server {}")}
+	variables := map[string]string{"TAG": "1.17", "TOKEN": "s3cr3t"}
+
+	if err := app.writeDeploy("bob", "blog", composeFile, files, variables); err != nil {
+		t.Fatalf("writeDeploy() = %v", err)
+	}
+
+	if stored, err := app.storeApp.ReadFile(getRequestVariablesStoreName("bob", "blog")); err != nil || bytes.Contains(stored, []byte("s3cr3t")) {
+		t.Errorf("stored request variables = (%s, %v), want encrypted content", stored, err)
+	}
+
+	resultCompose, resultFiles, resultVariables, err := app.readDeploy("bob", "blog")
+	if err != nil || !reflect.DeepEqual(resultCompose, composeFile) || !reflect.DeepEqual(resultFiles, files) || !reflect.DeepEqual(resultVariables, variables) {
+		t.Errorf("readDeploy() = (%s, %+v, %+v, %v), want (%s, %+v, %+v, nil)", resultCompose, resultFiles, resultVariables, err, composeFile, files, variables)
+	}
+
+	if err := app.writeDeploy("bob", "blog", composeFile, nil, nil); err != nil {
+		t.Fatalf("writeDeploy() without files = %v", err)
+	}
+
+	if _, resultFiles, resultVariables, err := app.readDeploy("bob", "blog"); err != nil || len(resultFiles) != 0 || len(resultVariables) != 0 {
+		t.Errorf("readDeploy() after deploy without files = (%+v, %+v, %v), want empty", resultFiles, resultVariables, err)
+	}
+
+	if resultCompose, _, _, err := app.readDeploy("alice", "blog"); err != nil || resultCompose != nil {
+		t.Errorf("readDeploy() of other owner = (%s, %v), want (nil, nil)", resultCompose, err)
+	}
+}
+
+func TestRedeployNotFound(t *testing.T) {
+	app, clean := newTestApp(t, "[]")
+	defer clean()
+
+	writer := httptest.NewRecorder()
+	app.redeploy(writer, httptest.NewRequest(http.MethodPost, "/blog/redeploy", nil), model.NewUser("0", "bob", "", ""), "blog")
+
+	if writer.Code != http.StatusNotFound {
+		t.Errorf("redeploy() without stored compose = %d, want %d", writer.Code, http.StatusNotFound)
+	}
+}
+
+type fakeDockerClient struct {
+	client.APIClient
+
+	containers []types.Container
+	failing    string
+	mutex      sync.Mutex
+	running    int
+	maxRun     int
+	created    []string
+	removed    []string
+}
+
+func (c *fakeDockerClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	return c.containers, nil
+}
+
+func (c *fakeDockerClient) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader("")), nil
+}
+
+func (c *fakeDockerClient) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	return types.ImageInspect{Config: &container.Config{}}, nil, nil
+}
+
+func (c *fakeDockerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error) {
+	c.mutex.Lock()
+	c.running++
+	if c.running > c.maxRun {
+		c.maxRun = c.running
+	}
+	c.mutex.Unlock()
+
+	failing := strings.Contains(containerName, c.failing)
+	if failing {
+		// let concurrent creations succeed before failing, leaving a partial set of containers
+		time.Sleep(30 * time.Millisecond)
+	} else {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.running--
+
+	if failing {
+		return container.ContainerCreateCreatedBody{}, errors.New("no space left on device")
+	}
+
+	c.created = append(c.created, containerName)
+	return container.ContainerCreateCreatedBody{ID: containerName}, nil
+}
+
+func (c *fakeDockerClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{ID: containerID},
+		Config:            &container.Config{},
+	}, nil
+}
+
+func (c *fakeDockerClient) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removed = append(c.removed, containerID)
+	return nil
+}
+
+func TestCreateContainers(t *testing.T) {
+	services := map[string]dockerComposeService{
+		"api":    {Image: "vibioh/api"},
+		"ui":     {Image: "vibioh/ui"},
+		"db":     {Image: "postgres"},
+		"cache":  {Image: "redis"},
+		"worker": {Image: "vibioh/worker"},
+		"proxy":  {Image: "nginx"},
+	}
+
+	var cases = []struct {
+		intention string
+		failing   string
+		want      int
+		wantErr   bool
+	}{
+		{
+			"should create all services with bounded concurrency",
+			"unknown",
+			len(services),
+			false,
+		},
+		{
+			"should remove created containers when a service fails",
+			"_db_",
+			0,
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		app, clean := newTestApp(t, "[]")
+		app.workers = 2
+
+		fake := &fakeDockerClient{failing: testCase.failing}
+		app.dockerApp.Docker = fake
+
+		user := model.NewUser("1", "bob", "", "")
+		result, err := app.createContainers(context.Background(), user, docker.Ownership{Owner: "bob"}, "blog", services, nil, tagOverrides{}, nil, "")
+		clean()
+
+		failed := false
+		if testCase.wantErr && err == nil {
+			failed = true
+		} else if !testCase.wantErr && err != nil {
+			failed = true
+		} else if len(result) != testCase.want {
+			failed = true
+		}
+
+		if failed {
+			t.Errorf("%s\ncreateContainers() = (%d services, %v), want (%d services, error %t)", testCase.intention, len(result), err, testCase.want, testCase.wantErr)
+		}
+
+		if fake.maxRun > int(app.workers) {
+			t.Errorf("%s\ncreateContainers() ran %d creations concurrently, want at most %d", testCase.intention, fake.maxRun, app.workers)
+		}
+
+		if testCase.wantErr && len(fake.removed) != len(fake.created) {
+			t.Errorf("%s\ncreateContainers() removed %v, want %v", testCase.intention, fake.removed, fake.created)
+		} else if !testCase.wantErr && len(fake.removed) != 0 {
+			t.Errorf("%s\ncreateContainers() removed %v, want none", testCase.intention, fake.removed)
+		}
+	}
+}
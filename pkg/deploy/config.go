@@ -2,6 +2,8 @@ package deploy
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,47 +14,87 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	units "github.com/docker/go-units"
 )
 
+func parseHealthcheckDuration(name, value string) (time.Duration, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New("invalid healthcheck %s: %v", name, err)
+	}
+
+	return duration, nil
+}
+
 func getHealthcheckConfig(healthcheck *dockerComposeHealthcheck) (*container.HealthConfig, error) {
+	if healthcheck.Disable {
+		if len(healthcheck.Test) != 0 && healthcheck.Test[0] != healthcheckNone {
+			return nil, errors.New("healthcheck disable cannot be combined with a test")
+		}
+
+		return &container.HealthConfig{Test: []string{healthcheckNone}}, nil
+	}
+
+	if len(healthcheck.Test) != 0 {
+		switch healthcheck.Test[0] {
+		case healthcheckNone:
+			return &container.HealthConfig{Test: []string{healthcheckNone}}, nil
+		case healthcheckCmd, healthcheckShell:
+			if len(healthcheck.Test) < 2 {
+				return nil, errors.New("healthcheck test %s requires a command", healthcheck.Test[0])
+			}
+		default:
+			return nil, errors.New("healthcheck test must start with %s, %s or %s", healthcheckNone, healthcheckCmd, healthcheckShell)
+		}
+	}
+
 	healthconfig := container.HealthConfig{
 		Test:    healthcheck.Test,
 		Retries: healthcheck.Retries,
 	}
 
-	if strings.TrimSpace(healthcheck.Interval) != "" {
-		interval, err := time.ParseDuration(healthcheck.Interval)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+	var err error
 
-		healthconfig.Interval = interval
+	if healthconfig.Interval, err = parseHealthcheckDuration("interval", healthcheck.Interval); err != nil {
+		return nil, err
 	}
 
-	if strings.TrimSpace(healthcheck.Timeout) != "" {
-		timeout, err := time.ParseDuration(healthcheck.Timeout)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+	if healthconfig.Timeout, err = parseHealthcheckDuration("timeout", healthcheck.Timeout); err != nil {
+		return nil, err
+	}
 
-		healthconfig.Timeout = timeout
+	if healthconfig.StartPeriod, err = parseHealthcheckDuration("start_period", healthcheck.StartPeriod); err != nil {
+		return nil, err
+	}
+
+	if healthconfig.StartPeriod > MaxStartPeriod {
+		return nil, errors.New("healthcheck start_period must not exceed %s", MaxStartPeriod)
 	}
 
 	return &healthconfig, nil
 }
 
-func (a *App) getConfig(service *dockerComposeService, user *model.User, appName string) (*container.Config, error) {
+func (a *App) getConfig(service *dockerComposeService, ownership docker.Ownership, appName string) (*container.Config, error) {
 	environments := make([]string, 0, len(service.Environment))
 	for key, value := range service.Environment {
-		environments = append(environments, fmt.Sprintf("%s=%s", key, value))
+		if value != nil {
+			environments = append(environments, fmt.Sprintf("%s=%s", key, *value))
+		}
 	}
 
 	if service.Labels == nil {
 		service.Labels = make(map[string]string)
 	}
 
-	service.Labels[commons.OwnerLabel] = user.Username
+	service.Labels[commons.OwnerLabel] = ownership.Owner
 	service.Labels[commons.AppLabel] = appName
+	if ownership.Team != "" {
+		service.Labels[commons.TeamLabel] = ownership.Team
+	}
 
 	config := container.Config{
 		Hostname: service.Hostname,
@@ -67,7 +109,24 @@ func (a *App) getConfig(service *dockerComposeService, user *model.User, appName
 	}
 
 	if len(service.Command) != 0 {
-		config.Cmd = service.Command
+		config.Cmd = []string(service.Command)
+	}
+
+	if len(service.Entrypoint) != 0 {
+		config.Entrypoint = []string(service.Entrypoint)
+	}
+
+	config.WorkingDir = service.WorkingDir
+	config.StopSignal = service.StopSignal
+
+	if strings.TrimSpace(service.StopGracePeriod) != "" {
+		stopGracePeriod, err := getStopGracePeriod(service.StopGracePeriod)
+		if err != nil {
+			return nil, err
+		}
+
+		stopTimeout := int(stopGracePeriod / time.Second)
+		config.StopTimeout = &stopTimeout
 	}
 
 	if service.Healthcheck != nil {
@@ -82,6 +141,52 @@ func (a *App) getConfig(service *dockerComposeService, user *model.User, appName
 	return &config, nil
 }
 
+func getServiceResources(service *dockerComposeService) (int64, int64) {
+	cpuShares := int64(defaultCPUShares)
+	if service.CPUShares != 0 {
+		cpuShares = service.CPUShares
+	}
+
+	memory := int64(minMemory)
+	if service.MemoryLimit != 0 {
+		if service.MemoryLimit <= maxMemory {
+			memory = service.MemoryLimit
+		} else {
+			memory = maxMemory
+		}
+	}
+
+	return cpuShares, memory
+}
+
+func getStopGracePeriod(value string) (time.Duration, error) {
+	stopGracePeriod, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	if stopGracePeriod < 0 || stopGracePeriod > maxStopGracePeriod {
+		return 0, errors.New("stop_grace_period must be between 0 and %s", maxStopGracePeriod)
+	}
+
+	return stopGracePeriod, nil
+}
+
+func getUlimitsConfig(ulimits map[string]dockerComposeUlimit) []*units.Ulimit {
+	names := make([]string, 0, len(ulimits))
+	for name := range ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	output := make([]*units.Ulimit, 0, len(ulimits))
+	for _, name := range names {
+		output = append(output, &units.Ulimit{Name: name, Soft: ulimits[name].Soft, Hard: ulimits[name].Hard})
+	}
+
+	return output
+}
+
 func getVolumesConfig(hostConfig *container.HostConfig, volumes []string) {
 	for _, rawVolume := range volumes {
 		parts := strings.Split(rawVolume, colonSeparator)
@@ -97,35 +202,121 @@ func getVolumesConfig(hostConfig *container.HostConfig, volumes []string) {
 	}
 }
 
-func (a *App) getHostConfig(service *dockerComposeService, user *model.User) *container.HostConfig {
+func getRestartPolicy(restart string) (container.RestartPolicy, error) {
+	if strings.TrimSpace(restart) == "" {
+		return container.RestartPolicy{Name: "on-failure", MaximumRetryCount: defaultRestartRetries}, nil
+	}
+
+	parts := strings.SplitN(restart, colonSeparator, 2)
+	policy := container.RestartPolicy{Name: parts[0]}
+
+	switch policy.Name {
+	case "no", "always", "unless-stopped":
+		if len(parts) > 1 {
+			return policy, errors.New("restart policy %s does not accept a maximum retry count", policy.Name)
+		}
+	case "on-failure":
+		if len(parts) > 1 {
+			count, err := strconv.Atoi(parts[1])
+			if err != nil || count < 0 {
+				return policy, errors.New("invalid maximum retry count `%s` for restart policy", parts[1])
+			}
+
+			policy.MaximumRetryCount = count
+		}
+	default:
+		return policy, errors.New("unknown restart policy `%s`", restart)
+	}
+
+	return policy, nil
+}
+
+func (a *App) getLogConfig(logging *dockerComposeLogging) (container.LogConfig, error) {
+	logConfig := container.LogConfig{Type: defaultLogDriver, Config: make(map[string]string)}
+
+	if logging != nil {
+		if logging.Driver != "" {
+			logConfig.Type = logging.Driver
+		}
+
+		for key, value := range logging.Options {
+			logConfig.Config[key] = value
+		}
+	}
+
+	if !a.isLogDriverAllowed(logConfig.Type) {
+		return logConfig, errors.New("logging driver %s is not allowed", logConfig.Type)
+	}
+
+	if logConfig.Type == "json-file" || logConfig.Type == "local" {
+		if _, ok := logConfig.Config["max-size"]; !ok {
+			logConfig.Config["max-size"] = defaultLogMaxSize
+		}
+	}
+
+	return logConfig, nil
+}
+
+func (a *App) isLogDriverAllowed(driver string) bool {
+	if driver == defaultLogDriver {
+		return true
+	}
+
+	for _, logDriver := range a.logDrivers {
+		if logDriver == driver {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *App) getHostConfig(service *dockerComposeService, user *model.User) (*container.HostConfig, error) {
+	restartPolicy, err := getRestartPolicy(service.Restart)
+	if err != nil {
+		return nil, err
+	}
+
+	logConfig, err := a.getLogConfig(service.Logging)
+	if err != nil {
+		return nil, err
+	}
+
+	cpuShares, memory := getServiceResources(service)
+
 	hostConfig := container.HostConfig{
-		LogConfig: container.LogConfig{Type: "json-file", Config: map[string]string{
-			"max-size": "10m",
-		}},
+		LogConfig:     logConfig,
 		NetworkMode:   container.NetworkMode(a.network),
-		RestartPolicy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 5},
+		RestartPolicy: restartPolicy,
 		Resources: container.Resources{
-			CPUShares: defaultCPUShares,
-			Memory:    minMemory,
+			CPUShares: cpuShares,
+			Memory:    memory,
 		},
 		SecurityOpt: []string{"no-new-privileges"},
 		DNS:         service.DNS,
+		Init:        service.Init,
+		Sysctls:     service.Sysctls,
 	}
 
-	if service.ReadOnly {
-		hostConfig.ReadonlyRootfs = true
+	if len(service.Ulimits) > 0 {
+		hostConfig.Resources.Ulimits = getUlimitsConfig(service.Ulimits)
 	}
 
-	if service.CPUShares != 0 {
-		hostConfig.Resources.CPUShares = service.CPUShares
-	}
+	if strings.TrimSpace(service.ShmSize) != "" {
+		shmSize, err := units.RAMInBytes(service.ShmSize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
 
-	if service.MemoryLimit != 0 {
-		if service.MemoryLimit <= maxMemory {
-			hostConfig.Resources.Memory = service.MemoryLimit
-		} else {
-			hostConfig.Resources.Memory = maxMemory
+		if shmSize <= 0 || shmSize > maxMemory {
+			return nil, errors.New("shm_size must be between 1 and %d bytes", maxMemory)
 		}
+
+		hostConfig.ShmSize = shmSize
+	}
+
+	if service.ReadOnly != nil && *service.ReadOnly {
+		hostConfig.ReadonlyRootfs = true
 	}
 
 	if docker.IsAdmin(user) {
@@ -146,7 +337,7 @@ func (a *App) getHostConfig(service *dockerComposeService, user *model.User) *co
 		}
 	}
 
-	return &hostConfig
+	return &hostConfig, nil
 }
 
 func addLinks(settings *network.EndpointSettings, links []string) {
@@ -162,17 +353,3 @@ func addLinks(settings *network.EndpointSettings, links []string) {
 		settings.Links = append(settings.Links, fmt.Sprintf("%s%s%s", target, colonSeparator, alias))
 	}
 }
-
-func (a *App) getNetworkConfig(serviceName string, service *dockerComposeService) *network.NetworkingConfig {
-	endpointConfig := network.EndpointSettings{}
-	endpointConfig.Aliases = append(endpointConfig.Aliases, serviceName)
-
-	addLinks(&endpointConfig, service.Links)
-	addLinks(&endpointConfig, service.ExternalLinks)
-
-	return &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			a.network: &endpointConfig,
-		},
-	}
-}
@@ -0,0 +1,201 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestGetHealthcheckConfig(t *testing.T) {
+	var cases = []struct {
+		intention   string
+		healthcheck string
+		want        *container.HealthConfig
+		wantErr     bool
+	}{
+		{
+			"should convert string test to shell",
+			"{test: curl -f http://localhost, interval: 10s, timeout: 5s, start_period: 30s, retries: 3}",
+			&container.HealthConfig{
+				Test:        []string{"CMD-SHELL", "curl -f http://localhost"},
+				Interval:    10 * time.Second,
+				Timeout:     5 * time.Second,
+				StartPeriod: 30 * time.Second,
+				Retries:     3,
+			},
+			false,
+		},
+		{
+			"should keep list test",
+			"{test: [CMD, /healthcheck]}",
+			&container.HealthConfig{Test: []string{"CMD", "/healthcheck"}},
+			false,
+		},
+		{
+			"should keep image test when only timings are overridden",
+			"{interval: 1m}",
+			&container.HealthConfig{Interval: time.Minute},
+			false,
+		},
+		{
+			"should disable healthcheck",
+			"{disable: true}",
+			&container.HealthConfig{Test: []string{"NONE"}},
+			false,
+		},
+		{
+			"should handle NONE test",
+			"{test: [NONE], interval: 10s}",
+			&container.HealthConfig{Test: []string{"NONE"}},
+			false,
+		},
+		{
+			"should reject disable with test",
+			"{disable: true, test: [CMD, /healthcheck]}",
+			nil,
+			true,
+		},
+		{
+			"should reject unknown test type",
+			"{test: [/healthcheck]}",
+			nil,
+			true,
+		},
+		{
+			"should reject long start period",
+			"{test: [CMD, /healthcheck], start_period: 1h}",
+			nil,
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		var healthcheck dockerComposeHealthcheck
+		if err := yaml.Unmarshal([]byte(testCase.healthcheck), &healthcheck); err != nil {
+			t.Errorf("%s\nyaml.Unmarshal(%s) = %v", testCase.intention, testCase.healthcheck, err)
+			continue
+		}
+
+		result, err := getHealthcheckConfig(&healthcheck)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\ngetHealthcheckConfig(%s) = %+v, want error", testCase.intention, testCase.healthcheck, result)
+			}
+		} else if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetHealthcheckConfig(%s) = (%+v, %v), want (%+v, nil)", testCase.intention, testCase.healthcheck, result, err, testCase.want)
+		}
+	}
+}
+
+func TestGetRestartPolicy(t *testing.T) {
+	var cases = []struct {
+		restart string
+		want    container.RestartPolicy
+		wantErr bool
+	}{
+		{
+			"",
+			container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 5},
+			false,
+		},
+		{
+			"no",
+			container.RestartPolicy{Name: "no"},
+			false,
+		},
+		{
+			"unless-stopped",
+			container.RestartPolicy{Name: "unless-stopped"},
+			false,
+		},
+		{
+			"on-failure",
+			container.RestartPolicy{Name: "on-failure"},
+			false,
+		},
+		{
+			"on-failure:3",
+			container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+			false,
+		},
+		{
+			"always:3",
+			container.RestartPolicy{},
+			true,
+		},
+		{
+			"on-failure:many",
+			container.RestartPolicy{},
+			true,
+		},
+		{
+			"sometimes",
+			container.RestartPolicy{},
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := getRestartPolicy(testCase.restart)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("getRestartPolicy(%#v) = %+v, want error", testCase.restart, result)
+			}
+		} else if err != nil || result != testCase.want {
+			t.Errorf("getRestartPolicy(%#v) = (%+v, %v), want (%+v, nil)", testCase.restart, result, err, testCase.want)
+		}
+	}
+}
+
+func TestGetLogConfig(t *testing.T) {
+	var cases = []struct {
+		intention string
+		logging   *dockerComposeLogging
+		want      container.LogConfig
+		wantErr   bool
+	}{
+		{
+			"should apply default driver",
+			nil,
+			container.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "10m"}},
+			false,
+		},
+		{
+			"should keep options",
+			&dockerComposeLogging{Driver: "local", Options: map[string]string{"max-size": "50m", "max-file": "3"}},
+			container.LogConfig{Type: "local", Config: map[string]string{"max-size": "50m", "max-file": "3"}},
+			false,
+		},
+		{
+			"should accept allowed driver",
+			&dockerComposeLogging{Driver: "syslog", Options: map[string]string{"tag": "api"}},
+			container.LogConfig{Type: "syslog", Config: map[string]string{"tag": "api"}},
+			false,
+		},
+		{
+			"should reject driver not allowed",
+			&dockerComposeLogging{Driver: "gelf"},
+			container.LogConfig{},
+			true,
+		},
+	}
+
+	app := App{logDrivers: []string{"local", "syslog"}}
+
+	for _, testCase := range cases {
+		result, err := app.getLogConfig(testCase.logging)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\ngetLogConfig(%+v) = %+v, want error", testCase.intention, testCase.logging, result)
+			}
+		} else if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetLogConfig(%+v) = (%+v, %v), want (%+v, nil)", testCase.intention, testCase.logging, result, err, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"path"
+	"sort"
+
+	"github.com/ViBiOh/httputils/pkg/errors"
+)
+
+const (
+	defaultConfigsDirectory = "/"
+	maxConfigsSize          = 4 << 20
+)
+
+func getConfigsContent(configs map[string]dockerComposeConfig, files map[string][]byte, maxSize uint) (map[string][]byte, error) {
+	contents := make(map[string][]byte)
+	totalSize := 0
+
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		config := configs[name]
+
+		var content []byte
+
+		if config.File != "" && config.Content != "" {
+			return nil, errors.New("config %s: file and content are mutually exclusive", name)
+		} else if config.File != "" {
+			var ok bool
+			if content, ok = files[path.Base(config.File)]; !ok {
+				return nil, errors.New("config %s: file %s not provided in request", name, config.File)
+			}
+		} else if config.Content != "" {
+			content = []byte(config.Content)
+		} else {
+			return nil, errors.New("config %s: file or content is required", name)
+		}
+
+		if uint(len(content)) > maxSize {
+			return nil, errors.New("config %s: size of %d bytes exceeds limit of %d bytes", name, len(content), maxSize)
+		}
+
+		totalSize += len(content)
+		contents[name] = content
+	}
+
+	if totalSize > maxConfigsSize {
+		return nil, errors.New("configs size of %d bytes exceeds limit of %d bytes", totalSize, maxConfigsSize)
+	}
+
+	return contents, nil
+}
+
+func getServiceConfigs(service *dockerComposeService, contents map[string][]byte) ([]containerFile, error) {
+	files := make([]containerFile, 0, len(service.Configs))
+
+	for _, reference := range service.Configs {
+		content, ok := contents[reference.Source]
+		if !ok {
+			return nil, errors.New("config %s is not defined", reference.Source)
+		}
+
+		file, err := getReferencedFile(reference, content, defaultConfigsDirectory)
+		if err != nil {
+			return nil, errors.New("config %s: %v", reference.Source, err)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,70 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetConfigsContent(t *testing.T) {
+	var cases = []struct {
+		intention string
+		configs   map[string]dockerComposeConfig
+		files     map[string][]byte
+		want      map[string][]byte
+		wantErr   bool
+	}{
+		{
+			"should handle no config",
+			nil,
+			nil,
+			map[string][]byte{},
+			false,
+		},
+		{
+			"should read inline content and request files",
+			map[string]dockerComposeConfig{
+				"nginx":      {File: "./conf/nginx.conf"},
+				"prometheus": {Content: "scrape_interval: 15s"},
+			},
+			map[string][]byte{"nginx.conf": []byte("worker_processes 1;")},
+			map[string][]byte{
+				"nginx":      []byte("worker_processes 1;"),
+				"prometheus": []byte("scrape_interval: 15s"),
+			},
+			false,
+		},
+		{
+			"should fail if file is not provided",
+			map[string]dockerComposeConfig{"nginx": {File: "nginx.conf"}},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"should fail if config is empty",
+			map[string]dockerComposeConfig{"nginx": {}},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"should fail if config is too large",
+			map[string]dockerComposeConfig{"nginx": {Content: "worker_processes 1; events {}"}},
+			nil,
+			nil,
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := getConfigsContent(testCase.configs, testCase.files, 24)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\ngetConfigsContent(%+v, %+v) = %+v, want error", testCase.intention, testCase.configs, testCase.files, result)
+			}
+		} else if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetConfigsContent(%+v, %+v) = (%+v, %v), want (%+v, nil)", testCase.intention, testCase.configs, testCase.files, result, err, testCase.want)
+		}
+	}
+}
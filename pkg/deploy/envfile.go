@@ -0,0 +1,236 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/httpjson"
+	"github.com/ViBiOh/httputils/pkg/request"
+)
+
+var (
+	envsRequest   = regexp.MustCompile(`^/([^/]+)/envs/?$`)
+	envRequest    = regexp.MustCompile(`^/([^/]+)/envs/([^/]+)/?$`)
+	validEnvName  = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	envWhitespace = regexp.MustCompile(`\s`)
+)
+
+func getEnvsStoreName(owner string, appName string) string {
+	return fmt.Sprintf("envs/%s/%s.json", owner, appName)
+}
+
+func parseEnvFile(content []byte) (dockerComposeEnvironment, error) {
+	environment := make(dockerComposeEnvironment)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if key == "" || envWhitespace.MatchString(key) {
+			return nil, errors.New("invalid variable at line %d", line)
+		}
+
+		if len(parts) == 2 {
+			environment[key] = &parts[1]
+		} else {
+			environment[key] = nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return environment, nil
+}
+
+func resolveEnvironment(service *dockerComposeService, envFiles map[string][]byte, variables map[string]string) (dockerComposeEnvironment, error) {
+	environment := make(dockerComposeEnvironment)
+
+	for _, envFile := range service.EnvFile {
+		content, ok := envFiles[path.Base(envFile)]
+		if !ok {
+			return nil, errors.New("env_file %s not provided in request nor stored for app", envFile)
+		}
+
+		values, err := parseEnvFile(content)
+		if err != nil {
+			return nil, errors.New("env_file %s: %v", envFile, err)
+		}
+
+		for key, value := range values {
+			environment[key] = value
+		}
+	}
+
+	for key, value := range service.Environment {
+		environment[key] = value
+	}
+
+	for key, value := range environment {
+		if value != nil {
+			continue
+		}
+
+		if variable, ok := variables[key]; ok {
+			environment[key] = &variable
+		} else {
+			delete(environment, key)
+		}
+	}
+
+	return environment, nil
+}
+
+func (a *App) readEnvs(user *model.User, appName string) (map[string]string, error) {
+	envs := make(map[string]string)
+	if err := a.secretApp.Read(getEnvsStoreName(a.dockerApp.GetAppOwner(user, appName), appName), &envs); err != nil {
+		return nil, err
+	}
+
+	return envs, nil
+}
+
+func (a *App) getEnvFiles(user *model.User, appName string, files map[string][]byte) (map[string][]byte, error) {
+	envs, err := a.readEnvs(user, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	envFiles := make(map[string][]byte, len(envs)+len(files))
+	for name, content := range envs {
+		envFiles[name] = []byte(content)
+	}
+
+	for name, content := range files {
+		envFiles[name] = content
+	}
+
+	return envFiles, nil
+}
+
+func (a *App) envsHandler(w http.ResponseWriter, r *http.Request, user *model.User, appName string) {
+	if !a.isValidAppName(w, user, appName) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !a.dockerApp.Can(user, docker.GetAction, appName) {
+		httperror.Forbidden(w)
+		return
+	}
+
+	envs, err := a.readEnvs(user, appName)
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := httpjson.ResponseArrayJSON(w, http.StatusOK, names, httpjson.IsPretty(r)); err != nil {
+		httperror.InternalServerError(w, err)
+	}
+}
+
+func (a *App) envHandler(w http.ResponseWriter, r *http.Request, user *model.User, appName string, name string) {
+	if !a.isValidAppName(w, user, appName) {
+		return
+	}
+
+	if !validEnvName.MatchString(name) {
+		httperror.BadRequest(w, errors.New("invalid env name `%s`", name))
+		return
+	}
+
+	if !a.dockerApp.Can(user, docker.DeployAction, appName) {
+		httperror.Forbidden(w)
+		return
+	}
+
+	storeName := getEnvsStoreName(a.dockerApp.GetAppOwner(user, appName), appName)
+
+	a.envsMutex.Lock()
+	defer a.envsMutex.Unlock()
+
+	envs, err := a.readEnvs(user, appName)
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		content, ok := envs[name]
+		if !ok {
+			httperror.NotFound(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(content)); err != nil {
+			httperror.InternalServerError(w, err)
+		}
+
+	case http.MethodPut:
+		content, err := request.ReadBodyRequest(r)
+		if err != nil {
+			httperror.BadRequest(w, err)
+			return
+		}
+
+		if _, err := parseEnvFile(content); err != nil {
+			httperror.BadRequest(w, err)
+			return
+		}
+
+		envs[name] = string(content)
+		if err := a.secretApp.Write(storeName, envs); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if _, ok := envs[name]; !ok {
+			httperror.NotFound(w)
+			return
+		}
+
+		delete(envs, name)
+		if err := a.secretApp.Write(storeName, envs); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,121 @@
+package deploy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestResolveEnvironment(t *testing.T) {
+	var cases = []struct {
+		intention string
+		service   string
+		envFiles  map[string][]byte
+		want      map[string]string
+		wantErr   bool
+	}{
+		{
+			"should handle mapping form",
+			"environment: {DEBUG: true, PORT: 1080}",
+			nil,
+			map[string]string{"DEBUG": "true", "PORT": "1080"},
+			false,
+		},
+		{
+			"should handle list form and resolve bare keys from variables",
+			"environment: [DEBUG=true, DOMAIN, MISSING]",
+			nil,
+			map[string]string{"DEBUG": "true", "DOMAIN": "vibioh.fr"},
+			false,
+		},
+		{
+			"should give precedence to environment then last env_file",
+			"{env_file: [./common.env, prod.env], environment: [PORT=8080]}",
+			map[string][]byte{
+				"common.env": []byte("# common\nPORT=1080\nENV=dev\nDEBUG=false\n"),
+				"prod.env":   []byte("ENV=prod\n\nDOMAIN\n"),
+			},
+			map[string]string{"PORT": "8080", "ENV": "prod", "DEBUG": "false", "DOMAIN": "vibioh.fr"},
+			false,
+		},
+		{
+			"should accept single env_file",
+			"env_file: prod.env",
+			map[string][]byte{"prod.env": []byte("ENV=prod")},
+			map[string]string{"ENV": "prod"},
+			false,
+		},
+		{
+			"should fail on missing env_file",
+			"env_file: prod.env",
+			nil,
+			nil,
+			true,
+		},
+		{
+			"should fail on invalid env_file",
+			"env_file: prod.env",
+			map[string][]byte{"prod.env": []byte("INVALID KEY=value")},
+			nil,
+			true,
+		},
+	}
+
+	variables := map[string]string{"DOMAIN": "vibioh.fr"}
+
+	for _, testCase := range cases {
+		var service dockerComposeService
+		if err := yaml.Unmarshal([]byte(testCase.service), &service); err != nil {
+			t.Errorf("%s\nyaml.Unmarshal(%s) = %v", testCase.intention, testCase.service, err)
+			continue
+		}
+
+		environment, err := resolveEnvironment(&service, testCase.envFiles, variables)
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\nresolveEnvironment(%s) = %+v, want error", testCase.intention, testCase.service, environment)
+			}
+			continue
+		}
+
+		result := make(map[string]string)
+		for key, value := range environment {
+			result[key] = *value
+		}
+
+		if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\nresolveEnvironment(%s) = (%+v, %v), want (%+v, nil)", testCase.intention, testCase.service, result, err, testCase.want)
+		}
+	}
+}
+
+func TestEnvHandlerEncryption(t *testing.T) {
+	app, clean := newTestApp(t, "[]")
+	defer clean()
+
+	user := model.NewUser("0", "bob", "", "")
+	content := "DATABASE_PASSWORD=s3cr3t"
+
+	writer := httptest.NewRecorder()
+	app.envHandler(writer, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(content)), user, "blog", "prod.env")
+	if writer.Code != http.StatusNoContent {
+		t.Fatalf("envHandler(PUT) = %d, want %d", writer.Code, http.StatusNoContent)
+	}
+
+	stored, err := app.storeApp.ReadFile(getEnvsStoreName("bob", "blog"))
+	if err != nil || len(stored) == 0 || bytes.Contains(stored, []byte("s3cr3t")) {
+		t.Errorf("stored env set = (%s, %v), want encrypted content", stored, err)
+	}
+
+	writer = httptest.NewRecorder()
+	app.envHandler(writer, httptest.NewRequest(http.MethodGet, "/", nil), user, "blog", "prod.env")
+	if result := writer.Body.String(); writer.Code != http.StatusOK || result != content {
+		t.Errorf("envHandler(GET) = (%d, %s), want (%d, %s)", writer.Code, result, http.StatusOK, content)
+	}
+}
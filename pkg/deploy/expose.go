@@ -0,0 +1,153 @@
+package deploy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ViBiOh/httputils/pkg/errors"
+)
+
+const (
+	traefikV1              = "1"
+	traefikV2              = "2"
+	defaultRateLimitPeriod = "1s"
+)
+
+func (a *App) getExposeHostname(appName, serviceName string, expose *dockerComposeExpose) (string, error) {
+	if hostname := strings.TrimSpace(expose.Hostname); hostname != "" {
+		return strings.ToLower(hostname), nil
+	}
+
+	if a.exposeDomain == "" {
+		return "", errors.New("x-expose hostname is required when no domain is configured")
+	}
+
+	return strings.ToLower(fmt.Sprintf("%s-%s.%s", serviceName, appName, a.exposeDomain)), nil
+}
+
+func checkExpose(expose *dockerComposeExpose) error {
+	if expose.Port < 0 || expose.Port > 65535 {
+		return errors.New("x-expose port %d is invalid", expose.Port)
+	}
+
+	if expose.PathPrefix != "" && !strings.HasPrefix(expose.PathPrefix, "/") {
+		return errors.New("x-expose path_prefix %s must start with /", expose.PathPrefix)
+	}
+
+	if expose.RateLimit != nil && (expose.RateLimit.Average <= 0 || expose.RateLimit.Burst < 0) {
+		return errors.New("x-expose rate_limit average must be positive and burst not negative")
+	}
+
+	return nil
+}
+
+func getTraefikV1Labels(hostname string, expose *dockerComposeExpose) map[string]string {
+	rule := fmt.Sprintf("Host:%s", hostname)
+	if expose.PathPrefix != "" {
+		rule = fmt.Sprintf("%s;PathPrefix:%s", rule, expose.PathPrefix)
+	}
+
+	labels := map[string]string{
+		"traefik.enable":        "true",
+		"traefik.frontend.rule": rule,
+	}
+
+	if expose.Port != 0 {
+		labels["traefik.port"] = strconv.Itoa(expose.Port)
+	}
+
+	if expose.TLS {
+		labels["traefik.frontend.entryPoints"] = "https"
+	}
+
+	if expose.RateLimit != nil {
+		period := expose.RateLimit.Period
+		if period == "" {
+			period = defaultRateLimitPeriod
+		}
+
+		labels["traefik.frontend.rateLimit.extractorFunc"] = "client.ip"
+		labels["traefik.frontend.rateLimit.rateSet.expose.period"] = period
+		labels["traefik.frontend.rateLimit.rateSet.expose.average"] = strconv.Itoa(expose.RateLimit.Average)
+		labels["traefik.frontend.rateLimit.rateSet.expose.burst"] = strconv.Itoa(expose.RateLimit.Burst)
+	}
+
+	return labels
+}
+
+func getTraefikV2Labels(name, hostname string, expose *dockerComposeExpose) map[string]string {
+	router := fmt.Sprintf("traefik.http.routers.%s", name)
+
+	rule := fmt.Sprintf("Host(`%s`)", hostname)
+	if expose.PathPrefix != "" {
+		rule = fmt.Sprintf("%s && PathPrefix(`%s`)", rule, expose.PathPrefix)
+	}
+
+	labels := map[string]string{
+		"traefik.enable":                  "true",
+		fmt.Sprintf("%s.rule", router):    rule,
+		fmt.Sprintf("%s.service", router): name,
+	}
+
+	if expose.Port != 0 {
+		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", name)] = strconv.Itoa(expose.Port)
+	}
+
+	if expose.TLS {
+		labels[fmt.Sprintf("%s.tls", router)] = "true"
+	}
+
+	if expose.RateLimit != nil {
+		middleware := fmt.Sprintf("traefik.http.middlewares.%s-ratelimit.ratelimit", name)
+
+		labels[fmt.Sprintf("%s.average", middleware)] = strconv.Itoa(expose.RateLimit.Average)
+		labels[fmt.Sprintf("%s.burst", middleware)] = strconv.Itoa(expose.RateLimit.Burst)
+		if expose.RateLimit.Period != "" {
+			labels[fmt.Sprintf("%s.period", middleware)] = expose.RateLimit.Period
+		}
+
+		labels[fmt.Sprintf("%s.middlewares", router)] = fmt.Sprintf("%s-ratelimit", name)
+	}
+
+	return labels
+}
+
+func (a *App) getExposeLabels(appName, serviceName string, expose *dockerComposeExpose) (map[string]string, error) {
+	if err := checkExpose(expose); err != nil {
+		return nil, err
+	}
+
+	hostname, err := a.getExposeHostname(appName, serviceName, expose)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.traefikVersion == traefikV2 {
+		return getTraefikV2Labels(fmt.Sprintf("%s-%s", appName, serviceName), hostname, expose), nil
+	}
+
+	return getTraefikV1Labels(hostname, expose), nil
+}
+
+func (a *App) exposeServices(appName string, services map[string]dockerComposeService) error {
+	for serviceName, service := range services {
+		if service.Expose == nil {
+			continue
+		}
+
+		labels, err := a.getExposeLabels(appName, serviceName, service.Expose)
+		if err != nil {
+			return errors.New("service=%s %v", serviceName, err)
+		}
+
+		for key, value := range service.Labels {
+			labels[key] = value
+		}
+
+		service.Labels = labels
+		services[serviceName] = service
+	}
+
+	return nil
+}
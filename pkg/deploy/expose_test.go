@@ -0,0 +1,94 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetExposeLabels(t *testing.T) {
+	var cases = []struct {
+		intention      string
+		traefikVersion string
+		exposeDomain   string
+		expose         *dockerComposeExpose
+		want           map[string]string
+		wantErr        bool
+	}{
+		{
+			"should default hostname with configured domain",
+			traefikV1,
+			"vibioh.fr",
+			&dockerComposeExpose{Port: 1080},
+			map[string]string{
+				"traefik.enable":        "true",
+				"traefik.frontend.rule": "Host:api-dashboard.vibioh.fr",
+				"traefik.port":          "1080",
+			},
+			false,
+		},
+		{
+			"should require hostname without configured domain",
+			traefikV1,
+			"",
+			&dockerComposeExpose{Port: 1080},
+			nil,
+			true,
+		},
+		{
+			"should reject invalid path prefix",
+			traefikV1,
+			"vibioh.fr",
+			&dockerComposeExpose{PathPrefix: "api"},
+			nil,
+			true,
+		},
+		{
+			"should generate traefik v1 labels",
+			traefikV1,
+			"vibioh.fr",
+			&dockerComposeExpose{Hostname: "API.vibioh.fr", Port: 1080, TLS: true, PathPrefix: "/api", RateLimit: &dockerComposeRateLimit{Average: 100, Burst: 200}},
+			map[string]string{
+				"traefik.enable":                                    "true",
+				"traefik.frontend.rule":                             "Host:api.vibioh.fr;PathPrefix:/api",
+				"traefik.port":                                      "1080",
+				"traefik.frontend.entryPoints":                      "https",
+				"traefik.frontend.rateLimit.extractorFunc":          "client.ip",
+				"traefik.frontend.rateLimit.rateSet.expose.period":  "1s",
+				"traefik.frontend.rateLimit.rateSet.expose.average": "100",
+				"traefik.frontend.rateLimit.rateSet.expose.burst":   "200",
+			},
+			false,
+		},
+		{
+			"should generate traefik v2 labels",
+			traefikV2,
+			"vibioh.fr",
+			&dockerComposeExpose{Port: 1080, TLS: true, PathPrefix: "/api", RateLimit: &dockerComposeRateLimit{Average: 100, Burst: 200, Period: "1m"}},
+			map[string]string{
+				"traefik.enable": "true",
+				"traefik.http.routers.dashboard-api.rule":                            "Host(`api-dashboard.vibioh.fr`) && PathPrefix(`/api`)",
+				"traefik.http.routers.dashboard-api.service":                         "dashboard-api",
+				"traefik.http.routers.dashboard-api.tls":                             "true",
+				"traefik.http.routers.dashboard-api.middlewares":                     "dashboard-api-ratelimit",
+				"traefik.http.services.dashboard-api.loadbalancer.server.port":       "1080",
+				"traefik.http.middlewares.dashboard-api-ratelimit.ratelimit.average": "100",
+				"traefik.http.middlewares.dashboard-api-ratelimit.ratelimit.burst":   "200",
+				"traefik.http.middlewares.dashboard-api-ratelimit.ratelimit.period":  "1m",
+			},
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		app := App{traefikVersion: testCase.traefikVersion, exposeDomain: testCase.exposeDomain}
+		result, err := app.getExposeLabels("dashboard", "api", testCase.expose)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\ngetExposeLabels(%+v) = %+v, want error", testCase.intention, testCase.expose, result)
+			}
+		} else if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetExposeLabels(%+v) = (%+v, %v), want (%+v, nil)", testCase.intention, testCase.expose, result, err, testCase.want)
+		}
+	}
+}
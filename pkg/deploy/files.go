@@ -0,0 +1,112 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/docker/docker/api/types"
+)
+
+const defaultFileMode = 0444
+
+type containerFile struct {
+	path    string
+	content []byte
+	mode    int64
+	uid     int
+	gid     int
+}
+
+func parseOwnership(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("invalid numeric id `%s`", value)
+	}
+
+	return id, nil
+}
+
+func getReferencedFile(reference dockerComposeFileReference, content []byte, defaultDirectory string) (containerFile, error) {
+	file := containerFile{
+		path:    reference.Target,
+		content: content,
+		mode:    int64(reference.Mode),
+	}
+
+	if file.path == "" {
+		file.path = path.Join(defaultDirectory, reference.Source)
+	} else if !path.IsAbs(file.path) {
+		file.path = path.Join(defaultDirectory, file.path)
+	}
+
+	if file.mode == 0 {
+		file.mode = defaultFileMode
+	}
+
+	var err error
+
+	if file.uid, err = parseOwnership(reference.UID); err != nil {
+		return file, err
+	}
+
+	if file.gid, err = parseOwnership(reference.GID); err != nil {
+		return file, err
+	}
+
+	return file, nil
+}
+
+func getFilesArchive(files []containerFile) (io.Reader, error) {
+	var buffer bytes.Buffer
+	archive := tar.NewWriter(&buffer)
+	now := time.Now()
+
+	for _, file := range files {
+		header := tar.Header{
+			Name:    strings.TrimPrefix(file.path, "/"),
+			Mode:    file.mode,
+			Uid:     file.uid,
+			Gid:     file.gid,
+			Size:    int64(len(file.content)),
+			ModTime: now,
+		}
+
+		if err := archive.WriteHeader(&header); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if _, err := archive.Write(file.content); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &buffer, nil
+}
+
+func (a *App) copyFiles(ctx context.Context, containerID string, files []containerFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	archive, err := getFilesArchive(files)
+	if err != nil {
+		return err
+	}
+
+	return errors.WithStack(a.dockerApp.Docker.CopyToContainer(ctx, containerID, "/", archive, types.CopyToContainerOptions{}))
+}
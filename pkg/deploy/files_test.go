@@ -0,0 +1,64 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestGetReferencedFile(t *testing.T) {
+	var cases = []struct {
+		intention string
+		input     string
+		content   string
+		want      containerFile
+		wantErr   bool
+	}{
+		{
+			"should default target and mode for short syntax",
+			"db_password",
+			"s3cr3t",
+			containerFile{path: "/run/secrets/db_password", content: []byte("s3cr3t"), mode: 0444},
+			false,
+		},
+		{
+			"should handle long syntax",
+			"{source: db_password, target: password, uid: '1000', gid: '1000', mode: 0400}",
+			"s3cr3t",
+			containerFile{path: "/run/secrets/password", content: []byte("s3cr3t"), mode: 0400, uid: 1000, gid: 1000},
+			false,
+		},
+		{
+			"should keep absolute target",
+			"{source: tls_key, target: /etc/ssl/private/key.pem}",
+			"key",
+			containerFile{path: "/etc/ssl/private/key.pem", content: []byte("key"), mode: 0444},
+			false,
+		},
+		{
+			"should reject non numeric owner",
+			"{source: db_password, uid: root}",
+			"s3cr3t",
+			containerFile{},
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		var reference dockerComposeFileReference
+		if err := yaml.Unmarshal([]byte(testCase.input), &reference); err != nil {
+			t.Errorf("%s\nyaml.Unmarshal(%s) = %v", testCase.intention, testCase.input, err)
+			continue
+		}
+
+		result, err := getReferencedFile(reference, []byte(testCase.content), defaultSecretsDirectory)
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\ngetReferencedFile(%+v) = %+v, want error", testCase.intention, reference, result)
+			}
+		} else if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetReferencedFile(%+v) = (%+v, %v), want (%+v, nil)", testCase.intention, reference, result, err, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/docker/docker/api/types/container"
+)
+
+const defaultTmpfsOptions = "rw,noexec,nosuid,size=64m"
+
+var defaultTmpfs = []string{"/tmp"}
+
+type hardeningProfile struct {
+	NonRoot    bool     `json:"nonRoot"`
+	ReadOnly   bool     `json:"readOnly"`
+	Tmpfs      []string `json:"tmpfs"`
+	CapDropAll bool     `json:"capDropAll"`
+	Seccomp    string   `json:"seccomp"`
+
+	seccompProfile string
+}
+
+func (h *hardeningProfile) loadSeccomp() error {
+	if h == nil || strings.TrimSpace(h.Seccomp) == "" {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(h.Seccomp)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, content); err != nil {
+		return errors.New("invalid seccomp profile %s: %v", h.Seccomp, err)
+	}
+
+	h.seccompProfile = buffer.String()
+	return nil
+}
+
+func isNonRootUser(user string) bool {
+	uid, err := strconv.Atoi(strings.SplitN(user, colonSeparator, 2)[0])
+	return err == nil && uid > 0
+}
+
+func hasInjectedFiles(service dockerComposeService) bool {
+	return len(service.Configs) > 0 || len(service.Secrets) > 0
+}
+
+func (h *hardeningProfile) checkService(service dockerComposeService) []string {
+	if h == nil {
+		return nil
+	}
+
+	violations := make([]string, 0)
+
+	if h.NonRoot && service.User != "" && !isNonRootUser(service.User) {
+		violations = append(violations, fmt.Sprintf("user %s must be a numeric non-root uid", service.User))
+	}
+
+	if h.ReadOnly && service.ReadOnly != nil && !*service.ReadOnly {
+		violations = append(violations, "read_only cannot be disabled")
+	}
+
+	if h.ReadOnly && hasInjectedFiles(service) {
+		violations = append(violations, "configs and secrets files cannot be written in a read-only root filesystem")
+	}
+
+	if h.CapDropAll && len(service.CapAdd) > 0 {
+		violations = append(violations, "cap_add is not allowed")
+	}
+
+	if len(service.SecurityOpt) > 0 {
+		violations = append(violations, "security_opt is not allowed")
+	}
+
+	return violations
+}
+
+func (h *hardeningProfile) apply(service *dockerComposeService, config *container.Config, hostConfig *container.HostConfig) error {
+	if h == nil {
+		return nil
+	}
+
+	if h.NonRoot && !isNonRootUser(config.User) {
+		return errors.New("user %s must be a numeric non-root uid", config.User)
+	}
+
+	if h.ReadOnly && service.ReadOnly == nil {
+		hostConfig.ReadonlyRootfs = true
+	}
+
+	if h.ReadOnly && hostConfig.ReadonlyRootfs {
+		tmpfs := h.Tmpfs
+		if len(tmpfs) == 0 {
+			tmpfs = defaultTmpfs
+		}
+
+		hostConfig.Tmpfs = make(map[string]string, len(tmpfs))
+		for _, path := range tmpfs {
+			hostConfig.Tmpfs[path] = defaultTmpfsOptions
+		}
+	}
+
+	if h.CapDropAll {
+		hostConfig.CapDrop = []string{"ALL"}
+	}
+
+	if h.seccompProfile != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, fmt.Sprintf("seccomp=%s", h.seccompProfile))
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestHardeningCheckService(t *testing.T) {
+	var cases = []struct {
+		intention string
+		profile   *hardeningProfile
+		service   string
+		want      []string
+	}{
+		{
+			"should accept anything without profile",
+			nil,
+			"{user: root, read_only: false, cap_add: [NET_ADMIN]}",
+			nil,
+		},
+		{
+			"should accept compliant service",
+			&hardeningProfile{NonRoot: true, ReadOnly: true, CapDropAll: true},
+			"{user: '1000:1000'}",
+			[]string{},
+		},
+		{
+			"should report violations",
+			&hardeningProfile{NonRoot: true, ReadOnly: true, CapDropAll: true},
+			"{user: root, read_only: false, cap_add: [NET_ADMIN], security_opt: [seccomp=unconfined]}",
+			[]string{"user root must be a numeric non-root uid", "read_only cannot be disabled", "cap_add is not allowed", "security_opt is not allowed"},
+		},
+		{
+			"should report writable rootfs for injected files",
+			&hardeningProfile{ReadOnly: true},
+			"{read_only: false, configs: [nginx]}",
+			[]string{"read_only cannot be disabled", "configs and secrets files cannot be written in a read-only root filesystem"},
+		},
+		{
+			"should report injected files with default rootfs",
+			&hardeningProfile{ReadOnly: true},
+			"{secrets: [db_password]}",
+			[]string{"configs and secrets files cannot be written in a read-only root filesystem"},
+		},
+		{
+			"should accept injected files without read only profile",
+			&hardeningProfile{NonRoot: true},
+			"{configs: [nginx]}",
+			[]string{},
+		},
+	}
+
+	for _, testCase := range cases {
+		var service dockerComposeService
+		if err := yaml.Unmarshal([]byte(testCase.service), &service); err != nil {
+			t.Errorf("%s\nyaml.Unmarshal(%s) = %v", testCase.intention, testCase.service, err)
+			continue
+		}
+
+		if result := testCase.profile.checkService(service); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ncheckService(%s) = %#v, want %#v", testCase.intention, testCase.service, result, testCase.want)
+		}
+	}
+}
+
+func TestHardeningApply(t *testing.T) {
+	var cases = []struct {
+		intention      string
+		profile        *hardeningProfile
+		service        string
+		user           string
+		wantHostConfig container.HostConfig
+		wantErr        bool
+	}{
+		{
+			"should apply hardened defaults",
+			&hardeningProfile{NonRoot: true, ReadOnly: true, CapDropAll: true, seccompProfile: `{"defaultAction":"SCMP_ACT_ERRNO"}`},
+			"{}",
+			"1000",
+			container.HostConfig{
+				ReadonlyRootfs: true,
+				Tmpfs:          map[string]string{"/tmp": defaultTmpfsOptions},
+				CapDrop:        []string{"ALL"},
+				SecurityOpt:    []string{`seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`},
+			},
+			false,
+		},
+		{
+			"should not drop read only default for injected files",
+			&hardeningProfile{ReadOnly: true},
+			"{secrets: [db_password]}",
+			"1000",
+			container.HostConfig{
+				ReadonlyRootfs: true,
+				Tmpfs:          map[string]string{"/tmp": defaultTmpfsOptions},
+			},
+			false,
+		},
+		{
+			"should add configured tmpfs for explicit read only",
+			&hardeningProfile{ReadOnly: true, Tmpfs: []string{"/tmp", "/var/cache"}},
+			"{read_only: true}",
+			"1000",
+			container.HostConfig{
+				ReadonlyRootfs: true,
+				Tmpfs:          map[string]string{"/tmp": defaultTmpfsOptions, "/var/cache": defaultTmpfsOptions},
+			},
+			false,
+		},
+		{
+			"should reject root default user",
+			&hardeningProfile{NonRoot: true},
+			"{}",
+			"0",
+			container.HostConfig{},
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		var service dockerComposeService
+		if err := yaml.Unmarshal([]byte(testCase.service), &service); err != nil {
+			t.Errorf("%s\nyaml.Unmarshal(%s) = %v", testCase.intention, testCase.service, err)
+			continue
+		}
+
+		hostConfig := container.HostConfig{ReadonlyRootfs: service.ReadOnly != nil && *service.ReadOnly}
+		err := testCase.profile.apply(&service, &container.Config{User: testCase.user}, &hostConfig)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\napply(%s) = %+v, want error", testCase.intention, testCase.service, hostConfig)
+			}
+		} else if err != nil || !reflect.DeepEqual(hostConfig, testCase.wantHostConfig) {
+			t.Errorf("%s\napply(%s) = (%+v, %v), want (%+v, nil)", testCase.intention, testCase.service, hostConfig, err, testCase.wantHostConfig)
+		}
+	}
+}
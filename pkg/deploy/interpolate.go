@@ -0,0 +1,161 @@
+package deploy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ViBiOh/httputils/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+type missingVariable struct {
+	name    string
+	message string
+}
+
+func (m missingVariable) Error() string {
+	if m.message == "" {
+		return m.name
+	}
+
+	return fmt.Sprintf("%s (%s)", m.name, m.message)
+}
+
+func resolveVariable(expression string, variables map[string]string) (string, error) {
+	name := variableName.FindString(expression)
+	if name == "" {
+		return "", errors.New("invalid interpolation format for ${%s}", expression)
+	}
+
+	modifier := expression[len(name):]
+	value, ok := variables[name]
+
+	switch {
+	case modifier == "":
+		return value, nil
+	case strings.HasPrefix(modifier, ":-"):
+		if !ok || value == "" {
+			return modifier[2:], nil
+		}
+	case strings.HasPrefix(modifier, "-"):
+		if !ok {
+			return modifier[1:], nil
+		}
+	case strings.HasPrefix(modifier, ":?"):
+		if !ok || value == "" {
+			return "", missingVariable{name, modifier[2:]}
+		}
+	case strings.HasPrefix(modifier, "?"):
+		if !ok {
+			return "", missingVariable{name, modifier[1:]}
+		}
+	default:
+		return "", errors.New("invalid interpolation format for ${%s}", expression)
+	}
+
+	return value, nil
+}
+
+func interpolate(content string, variables map[string]string) (string, error) {
+	var output strings.Builder
+
+	for index := 0; index < len(content); index++ {
+		if content[index] != '$' || index+1 >= len(content) {
+			output.WriteByte(content[index])
+			continue
+		}
+
+		next := content[index+1]
+
+		if next == '$' {
+			output.WriteByte('$')
+			index++
+		} else if next == '{' {
+			end := strings.IndexByte(content[index+2:], '}')
+			if end == -1 {
+				return "", errors.New("invalid interpolation format for %s", content[index:])
+			}
+
+			value, err := resolveVariable(content[index+2:index+2+end], variables)
+			if err != nil {
+				return "", err
+			}
+
+			output.WriteString(value)
+			index += end + 2
+		} else if name := variableName.FindString(content[index+1:]); name != "" {
+			output.WriteString(variables[name])
+			index += len(name)
+		} else {
+			output.WriteByte('$')
+		}
+	}
+
+	return output.String(), nil
+}
+
+func interpolateNode(node *yaml.Node, variables map[string]string, missings *[]string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, item := range node.Content {
+			if err := interpolateNode(item, variables, missings); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for pair := 1; pair < len(node.Content); pair += 2 {
+			if err := interpolateNode(node.Content[pair], variables, missings); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+
+		output, err := interpolate(node.Value, variables)
+		if missing, ok := err.(missingVariable); ok {
+			*missings = append(*missings, missing.Error())
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if output != node.Value {
+			node.Value = output
+
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+
+	return nil
+}
+
+func interpolateCompose(composeFile []byte, variables map[string]string) ([]byte, error) {
+	content, err := unmarshalNode(composeFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if content == nil {
+		return composeFile, nil
+	}
+
+	missings := make([]string, 0)
+	if err := interpolateNode(content, variables, &missings); err != nil {
+		return nil, err
+	}
+
+	if len(missings) > 0 {
+		sort.Strings(missings)
+		return nil, errors.New("required variables are not set: %s", strings.Join(missings, ", "))
+	}
+
+	return marshalNode(content)
+}
@@ -0,0 +1,127 @@
+package deploy
+
+import (
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	variables := map[string]string{
+		"USER":  "vibioh",
+		"EMPTY": "",
+	}
+
+	var cases = []struct {
+		intention string
+		content   string
+		want      string
+		wantErr   string
+	}{
+		{
+			"should keep content without variable",
+			"dashboard",
+			"dashboard",
+			"",
+		},
+		{
+			"should unescape dollar",
+			"^dashboard.vibioh.fr$$",
+			"^dashboard.vibioh.fr$",
+			"",
+		},
+		{
+			"should replace braced and unbraced variables",
+			"${USER}:$USER:${UNKNOWN}",
+			"vibioh:vibioh:",
+			"",
+		},
+		{
+			"should handle default values",
+			"${EMPTY:-default}|${EMPTY-default}|${UNKNOWN-default}",
+			"default||default",
+			"",
+		},
+		{
+			"should report required variable",
+			"${EMPTY:?must be set}",
+			"",
+			"EMPTY (must be set)",
+		},
+		{
+			"should accept empty required variable",
+			"${EMPTY?must be set}",
+			"",
+			"",
+		},
+		{
+			"should reject invalid format",
+			"${USER",
+			"",
+			"invalid interpolation format for ${USER",
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := interpolate(testCase.content, variables)
+
+		errResult := ""
+		if err != nil {
+			errResult = err.Error()
+		}
+
+		if result != testCase.want || errResult != testCase.wantErr {
+			t.Errorf("%s\ninterpolate(%v) = (%v, %v), want (%v, %v)", testCase.intention, testCase.content, result, errResult, testCase.want, testCase.wantErr)
+		}
+	}
+}
+
+func TestInterpolateCompose(t *testing.T) {
+	var cases = []struct {
+		intention   string
+		composeFile string
+		variables   map[string]string
+		want        string
+		wantErr     string
+	}{
+		{
+			"should interpolate values without injecting yaml",
+			"services:\n  api:\n    image: vibioh/api:${TAG}\n",
+			map[string]string{"TAG": "1.0.0\n    privileged: true"},
+			"services:\n  api:\n    image: |-\n      vibioh/api:1.0.0\n          privileged: true\n",
+			"",
+		},
+		{
+			"should keep yaml 1.1 scalars as written",
+			"services:\n  api:\n    image: vibioh/api\n    restart: no\n    environment:\n      VERSION: 1.10\n      DEBUG: on\n",
+			nil,
+			"services:\n  api:\n    image: vibioh/api\n    restart: no\n    environment:\n      VERSION: 1.10\n      DEBUG: on\n",
+			"",
+		},
+		{
+			"should resolve type of interpolated plain values",
+			"services:\n  api:\n    cpu_shares: ${CPU}\n    read_only: ${RO}\n    environment:\n      CPU: '${CPU}'\n",
+			map[string]string{"CPU": "512", "RO": "true"},
+			"services:\n  api:\n    cpu_shares: 512\n    read_only: true\n    environment:\n      CPU: '512'\n",
+			"",
+		},
+		{
+			"should report every missing variable",
+			"services:\n  api:\n    image: ${IMAGE:?}\n    user: ${USER:?no root}\n",
+			nil,
+			"",
+			"required variables are not set: IMAGE, USER (no root)",
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := interpolateCompose([]byte(testCase.composeFile), testCase.variables)
+
+		errResult := ""
+		if err != nil {
+			errResult = err.Error()
+		}
+
+		if string(result) != testCase.want || errResult != testCase.wantErr {
+			t.Errorf("%s\ninterpolateCompose(%v) = (%s, %v), want (%v, %v)", testCase.intention, testCase.composeFile, result, errResult, testCase.want, testCase.wantErr)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/docker/docker/api/types"
+)
+
+const hostnamesStoreName = "hostnames.json"
+
+var (
+	reservedLabels = []string{
+		commons.OwnerLabel,
+		commons.TeamLabel,
+		commons.AppLabel,
+		commons.ServiceLabel,
+		commons.ImageLabel,
+		commons.TagLabel,
+		commons.DigestLabel,
+		commons.SecretsLabel,
+		traefikNetworkLabel,
+		"traefik.backend",
+		"traefik.tags",
+		"traefik.frontend.priority",
+	}
+
+	traefikV1Rule      = regexp.MustCompile(`^traefik\.(?:[^.]+\.)?frontend\.rule$`)
+	traefikV2Rule      = regexp.MustCompile(`^traefik\.(?:http|tcp)\.routers\.[^.]+\.rule$`)
+	traefikV2Name      = regexp.MustCompile(`^traefik\.(?:http|tcp|udp)\.(?:routers|services|middlewares)\.([^.]+)\.`)
+	traefikV2Host      = regexp.MustCompile("(?i)\\bHost(?:SNI|Header)?\\(([^)]*)\\)")
+	traefikV2Operators = regexp.MustCompile(`\|\||!`)
+	traefikHostRegexp  = regexp.MustCompile(`(?i)hostregexp`)
+	traefikV1Separator = regexp.MustCompile(`\s*;\s*`)
+)
+
+type hostnameClaim struct {
+	Owner string `json:"owner"`
+	App   string `json:"app"`
+}
+
+func loadDomains(filename string) (map[string][]string, error) {
+	if strings.TrimSpace(filename) == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var domains map[string][]string
+	if err := json.Unmarshal(content, &domains); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return domains, nil
+}
+
+func matchDomain(patterns []string, hostname string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(hostname, pattern[1:]) {
+				return true
+			}
+		} else if pattern == hostname {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isReservedLabel(label string) bool {
+	for _, reservedLabel := range reservedLabels {
+		if label == reservedLabel {
+			return true
+		}
+	}
+
+	return strings.HasPrefix(label, traefikLabelPrefix) && strings.HasSuffix(label, ".priority")
+}
+
+func getRuleHostnames(label string, rule string) ([]string, error) {
+	if traefikHostRegexp.MatchString(rule) {
+		return nil, errors.New("HostRegexp is not allowed in %s", label)
+	}
+
+	hostnames := make([]string, 0)
+
+	if traefikV1Rule.MatchString(label) {
+		for _, matcher := range traefikV1Separator.Split(strings.TrimSpace(rule), -1) {
+			parts := strings.SplitN(matcher, colonSeparator, 2)
+			if len(parts) != 2 || !strings.EqualFold(strings.TrimSpace(parts[0]), "host") {
+				continue
+			}
+
+			for _, hostname := range strings.Split(parts[1], ",") {
+				hostnames = append(hostnames, strings.ToLower(strings.TrimSpace(hostname)))
+			}
+		}
+	} else {
+		for _, match := range traefikV2Host.FindAllStringSubmatch(rule, -1) {
+			for _, hostname := range strings.Split(match[1], ",") {
+				hostnames = append(hostnames, strings.ToLower(strings.Trim(hostname, " `\"'")))
+			}
+		}
+	}
+
+	return hostnames, nil
+}
+
+func isRuleLabel(label string) bool {
+	return traefikV1Rule.MatchString(label) || traefikV2Rule.MatchString(label)
+}
+
+func getLabelsHostnames(labels map[string]string) ([]string, error) {
+	hostnames := make([]string, 0)
+
+	for label, value := range labels {
+		if !isRuleLabel(label) {
+			continue
+		}
+
+		ruleHostnames, err := getRuleHostnames(label, value)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hostname := range ruleHostnames {
+			if hostname != "" {
+				hostnames = append(hostnames, hostname)
+			}
+		}
+	}
+
+	sort.Strings(hostnames)
+	return hostnames, nil
+}
+
+func checkLabels(appName string, labels map[string]string) []string {
+	rejections := make([]string, 0)
+
+	for label := range labels {
+		if isReservedLabel(label) {
+			rejections = append(rejections, fmt.Sprintf("label %s is reserved", label))
+		} else if matches := traefikV2Name.FindStringSubmatch(label); matches != nil && matches[1] != appName && !strings.HasPrefix(matches[1], fmt.Sprintf("%s-", appName)) {
+			rejections = append(rejections, fmt.Sprintf("label %s must be named after app %s", label, appName))
+		}
+	}
+
+	sort.Strings(rejections)
+	return rejections
+}
+
+func checkRules(labels map[string]string) []string {
+	rejections := make([]string, 0)
+
+	for label, value := range labels {
+		if !isRuleLabel(label) {
+			continue
+		}
+
+		if traefikV2Rule.MatchString(label) && traefikV2Operators.MatchString(value) {
+			rejections = append(rejections, fmt.Sprintf("rule %s must combine matchers with && only", label))
+			continue
+		}
+
+		hostnames, err := getRuleHostnames(label, value)
+		if err != nil {
+			continue
+		}
+
+		hasHostname := false
+		for _, hostname := range hostnames {
+			if strings.Contains(hostname, "*") {
+				rejections = append(rejections, fmt.Sprintf("rule %s must not use wildcard hostname", label))
+			} else if hostname != "" {
+				hasHostname = true
+			}
+		}
+
+		if !hasHostname {
+			rejections = append(rejections, fmt.Sprintf("rule %s requires a Host matcher", label))
+		}
+	}
+
+	sort.Strings(rejections)
+	return rejections
+}
+
+func checkHostname(owner, hostname string, claims map[string]hostnameClaim, domains map[string][]string) string {
+	if claim, claimed := claims[hostname]; claimed {
+		if claim.Owner == owner {
+			return ""
+		}
+
+		return fmt.Sprintf("hostname %s is already used", hostname)
+	}
+
+	if matchDomain(domains[owner], hostname) {
+		return ""
+	}
+
+	for domainOwner, patterns := range domains {
+		if domainOwner != owner && matchDomain(patterns, hostname) {
+			return fmt.Sprintf("hostname %s is reserved", hostname)
+		}
+	}
+
+	return ""
+}
+
+func (a *App) getHostnameClaims(ctx context.Context) (map[string]hostnameClaim, error) {
+	claims := make(map[string]hostnameClaim)
+	if err := a.storeApp.Read(hostnamesStoreName, &claims); err != nil {
+		return nil, err
+	}
+
+	containers, err := a.dockerApp.Docker.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, container := range containers {
+		owner := container.Labels[commons.OwnerLabel]
+		if owner == "" {
+			continue
+		}
+
+		hostnames, err := getLabelsHostnames(container.Labels)
+		if err != nil {
+			continue
+		}
+
+		for _, hostname := range hostnames {
+			if _, ok := claims[hostname]; !ok {
+				claims[hostname] = hostnameClaim{Owner: owner, App: container.Labels[commons.AppLabel]}
+			}
+		}
+	}
+
+	for hostname, claim := range a.pendingHostnames {
+		if _, ok := claims[hostname]; !ok {
+			claims[hostname] = claim
+		}
+	}
+
+	return claims, nil
+}
+
+func (a *App) checkTraefikLabels(ctx context.Context, user *model.User, ownership docker.Ownership, appName string, services map[string]dockerComposeService) error {
+	a.hostnamesMutex.Lock()
+	defer a.hostnamesMutex.Unlock()
+
+	claims, err := a.getHostnameClaims(ctx)
+	if err != nil {
+		return err
+	}
+
+	isAdmin := docker.IsAdmin(user)
+
+	serviceNames := make([]string, 0, len(services))
+	for serviceName := range services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	rejections := make([]string, 0)
+	appHostnames := make([]string, 0)
+
+	for _, serviceName := range serviceNames {
+		labels := services[serviceName].Labels
+
+		if !isAdmin {
+			for _, rejection := range append(checkLabels(appName, labels), checkRules(labels)...) {
+				rejections = append(rejections, fmt.Sprintf("service=%s %s", serviceName, rejection))
+			}
+		}
+
+		hostnames, err := getLabelsHostnames(labels)
+		if err != nil {
+			if !isAdmin {
+				rejections = append(rejections, fmt.Sprintf("service=%s %v", serviceName, err))
+			}
+			continue
+		}
+
+		appHostnames = append(appHostnames, hostnames...)
+
+		if isAdmin {
+			continue
+		}
+
+		for _, hostname := range hostnames {
+			if rejection := checkHostname(ownership.Owner, hostname, claims, a.domains); rejection != "" {
+				rejections = append(rejections, fmt.Sprintf("service=%s %s", serviceName, rejection))
+			}
+		}
+	}
+
+	if len(rejections) > 0 {
+		return errors.New("rejected by label policy: %s", strings.Join(rejections, ", "))
+	}
+
+	a.reserveHostnames(ownership.Owner, appName, appHostnames)
+
+	return nil
+}
+
+func (a *App) reserveHostnames(owner, appName string, hostnames []string) {
+	if a.pendingHostnames == nil {
+		a.pendingHostnames = make(map[string]hostnameClaim)
+	}
+
+	removeAppClaims(a.pendingHostnames, appName)
+
+	for _, hostname := range hostnames {
+		a.pendingHostnames[hostname] = hostnameClaim{Owner: owner, App: appName}
+	}
+}
+
+func (a *App) releasePendingHostnames(appName string) {
+	a.hostnamesMutex.Lock()
+	defer a.hostnamesMutex.Unlock()
+
+	removeAppClaims(a.pendingHostnames, appName)
+}
+
+func (a *App) updateHostnameClaims(update func(map[string]hostnameClaim)) error {
+	if !a.storeApp.Enabled() {
+		return nil
+	}
+
+	a.hostnamesMutex.Lock()
+	defer a.hostnamesMutex.Unlock()
+
+	claims := make(map[string]hostnameClaim)
+	if err := a.storeApp.Read(hostnamesStoreName, &claims); err != nil {
+		return err
+	}
+
+	update(claims)
+
+	return a.storeApp.Write(hostnamesStoreName, claims)
+}
+
+func removeAppClaims(claims map[string]hostnameClaim, appName string) {
+	for hostname, claim := range claims {
+		if claim.App == appName {
+			delete(claims, hostname)
+		}
+	}
+}
+
+func (a *App) claimHostnames(owner, appName string, services map[string]*deployedService) error {
+	return a.updateHostnameClaims(func(claims map[string]hostnameClaim) {
+		removeAppClaims(claims, appName)
+
+		for _, service := range services {
+			for _, hostname := range service.hostnames {
+				claims[hostname] = hostnameClaim{Owner: owner, App: appName}
+			}
+		}
+	})
+}
+
+func (a *App) transferHostnames(appName, owner string) error {
+	return a.updateHostnameClaims(func(claims map[string]hostnameClaim) {
+		for hostname, claim := range claims {
+			if claim.App == appName {
+				claims[hostname] = hostnameClaim{Owner: owner, App: appName}
+			}
+		}
+	})
+}
+
+func (a *App) releaseHostnames(appName string) {
+	err := a.updateHostnameClaims(func(claims map[string]hostnameClaim) {
+		removeAppClaims(claims, appName)
+	})
+
+	if err != nil {
+		logger.Error("app=%s %+v", appName, err)
+	}
+}
@@ -0,0 +1,241 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetLabelsHostnames(t *testing.T) {
+	var cases = []struct {
+		intention string
+		labels    map[string]string
+		want      []string
+		wantErr   bool
+	}{
+		{
+			"should ignore labels without rule",
+			map[string]string{"traefik.port": "1080"},
+			[]string{},
+			false,
+		},
+		{
+			"should parse traefik v1 rules",
+			map[string]string{
+				"traefik.frontend.rule":       "Host: api.vibioh.fr,API.vibioh.fr; PathPrefix:/api",
+				"traefik.admin.frontend.rule": "Host:admin.vibioh.fr",
+			},
+			[]string{"admin.vibioh.fr", "api.vibioh.fr", "api.vibioh.fr"},
+			false,
+		},
+		{
+			"should parse traefik v2 rules",
+			map[string]string{
+				"traefik.http.routers.api.rule": "Host(`api.vibioh.fr`, `www.vibioh.fr`) && PathPrefix(`/api`)",
+				"traefik.tcp.routers.db.rule":   "HostSNI(`db.vibioh.fr`)",
+			},
+			[]string{"api.vibioh.fr", "db.vibioh.fr", "www.vibioh.fr"},
+			false,
+		},
+		{
+			"should parse host header matcher",
+			map[string]string{"traefik.http.routers.api.rule": "HostHeader(`victim.vibioh.fr`)"},
+			[]string{"victim.vibioh.fr"},
+			false,
+		},
+		{
+			"should reject host regexp",
+			map[string]string{"traefik.http.routers.api.rule": "HostRegexp(`{subdomain:[a-z]+}.vibioh.fr`)"},
+			nil,
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := getLabelsHostnames(testCase.labels)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\ngetLabelsHostnames(%+v) = %+v, want error", testCase.intention, testCase.labels, result)
+			}
+		} else if err != nil || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetLabelsHostnames(%+v) = (%+v, %v), want (%+v, nil)", testCase.intention, testCase.labels, result, err, testCase.want)
+		}
+	}
+}
+
+func TestCheckLabels(t *testing.T) {
+	var cases = []struct {
+		intention string
+		labels    map[string]string
+		want      []string
+	}{
+		{
+			"should accept user labels",
+			map[string]string{"traefik.frontend.rule": "Host:api.vibioh.fr", "traefik.http.routers.app-api.rule": "Host(`api.vibioh.fr`)", "custom": "value"},
+			[]string{},
+		},
+		{
+			"should reject reserved and conflicting labels",
+			map[string]string{"owner": "admin", "traefik.docker.network": "internal", "traefik.http.routers.api.priority": "1000", "traefik.http.services.other.loadbalancer.server.port": "80"},
+			[]string{
+				"label owner is reserved",
+				"label traefik.docker.network is reserved",
+				"label traefik.http.routers.api.priority is reserved",
+				"label traefik.http.services.other.loadbalancer.server.port must be named after app app",
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := checkLabels("app", testCase.labels); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ncheckLabels(%+v) = %#v, want %#v", testCase.intention, testCase.labels, result, testCase.want)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	var cases = []struct {
+		intention string
+		labels    map[string]string
+		want      []string
+	}{
+		{
+			"should accept rules with host",
+			map[string]string{"traefik.frontend.rule": "Host:api.vibioh.fr;PathPrefix:/api", "traefik.http.routers.app.rule": "Host(`api.vibioh.fr`) && PathPrefix(`/api`)"},
+			[]string{},
+		},
+		{
+			"should reject rules without host",
+			map[string]string{"traefik.frontend.rule": "PathPrefix:/", "traefik.http.routers.app.rule": "PathPrefix(`/`)"},
+			[]string{
+				"rule traefik.frontend.rule requires a Host matcher",
+				"rule traefik.http.routers.app.rule requires a Host matcher",
+			},
+		},
+		{
+			"should reject rules bypassing host",
+			map[string]string{"traefik.http.routers.app.rule": "Host(`api.vibioh.fr`) || PathPrefix(`/`)", "traefik.http.routers.app-not.rule": "!Host(`api.vibioh.fr`)"},
+			[]string{
+				"rule traefik.http.routers.app-not.rule must combine matchers with && only",
+				"rule traefik.http.routers.app.rule must combine matchers with && only",
+			},
+		},
+		{
+			"should reject wildcard hostname",
+			map[string]string{"traefik.tcp.routers.app.rule": "HostSNI(`*`)"},
+			[]string{
+				"rule traefik.tcp.routers.app.rule must not use wildcard hostname",
+				"rule traefik.tcp.routers.app.rule requires a Host matcher",
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := checkRules(testCase.labels); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ncheckRules(%+v) = %#v, want %#v", testCase.intention, testCase.labels, result, testCase.want)
+		}
+	}
+}
+
+func TestCheckHostname(t *testing.T) {
+	var cases = []struct {
+		intention string
+		hostname  string
+		want      string
+	}{
+		{
+			"should accept unclaimed hostname",
+			"new.vibioh.fr",
+			"",
+		},
+		{
+			"should accept hostname claimed by app owner",
+			"team.vibioh.fr",
+			"",
+		},
+		{
+			"should reject hostname claimed by deploying member",
+			"api.vibioh.fr",
+			"hostname api.vibioh.fr is already used",
+		},
+		{
+			"should reject hostname claimed by another user",
+			"admin.vibioh.fr",
+			"hostname admin.vibioh.fr is already used",
+		},
+		{
+			"should reject hostname claimed by same app of another owner",
+			"www.vibioh.fr",
+			"hostname www.vibioh.fr is already used",
+		},
+		{
+			"should accept domain allowed to app owner",
+			"www.bob.fr",
+			"",
+		},
+		{
+			"should reject domain allowed to app owner if claimed by another user",
+			"blog.bob.fr",
+			"hostname blog.bob.fr is already used",
+		},
+		{
+			"should reject domain allowed to deploying member",
+			"new.test.fr",
+			"hostname new.test.fr is reserved",
+		},
+		{
+			"should reject domain allowed to another user",
+			"www.admin.fr",
+			"hostname www.admin.fr is reserved",
+		},
+	}
+
+	claims := map[string]hostnameClaim{
+		"api.vibioh.fr":   {Owner: "test", App: "api"},
+		"admin.vibioh.fr": {Owner: "admin", App: "dashboard"},
+		"blog.bob.fr":     {Owner: "admin", App: "blog"},
+		"www.vibioh.fr":   {Owner: "admin", App: "website"},
+		"team.vibioh.fr":  {Owner: "bob", App: "website"},
+	}
+	domains := map[string][]string{
+		"bob":   {"*.bob.fr"},
+		"test":  {"*.test.fr"},
+		"admin": {"*.admin.fr"},
+	}
+	owner := "bob"
+
+	for _, testCase := range cases {
+		if result := checkHostname(owner, testCase.hostname, claims, domains); result != testCase.want {
+			t.Errorf("%s\ncheckHostname(%s, %s) = %#v, want %#v", testCase.intention, owner, testCase.hostname, result, testCase.want)
+		}
+	}
+}
+
+func TestReserveHostnames(t *testing.T) {
+	app := App{}
+
+	app.reserveHostnames("bob", "blog", []string{"blog.vibioh.fr", "www.vibioh.fr"})
+	app.reserveHostnames("alice", "api", []string{"api.vibioh.fr"})
+	app.reserveHostnames("bob", "blog", []string{"blog.vibioh.fr"})
+
+	want := map[string]hostnameClaim{
+		"blog.vibioh.fr": {Owner: "bob", App: "blog"},
+		"api.vibioh.fr":  {Owner: "alice", App: "api"},
+	}
+	if !reflect.DeepEqual(app.pendingHostnames, want) {
+		t.Errorf("reserveHostnames() = %+v, want %+v", app.pendingHostnames, want)
+	}
+
+	if result := checkHostname("carol", "blog.vibioh.fr", app.pendingHostnames, nil); result != "hostname blog.vibioh.fr is already used" {
+		t.Errorf("checkHostname() on pending hostname = %#v, want already used", result)
+	}
+
+	app.releasePendingHostnames("blog")
+
+	want = map[string]hostnameClaim{
+		"api.vibioh.fr": {Owner: "alice", App: "api"},
+	}
+	if !reflect.DeepEqual(app.pendingHostnames, want) {
+		t.Errorf("releasePendingHostnames() = %+v, want %+v", app.pendingHostnames, want)
+	}
+}
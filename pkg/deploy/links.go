@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/httputils/pkg/errors"
+)
+
+type ownerFinder func(containerName string) (string, error)
+
+func getLinkTarget(link string) string {
+	return strings.Split(link, colonSeparator)[0]
+}
+
+func checkLinks(user *model.User, services map[string]dockerComposeService, findOwner ownerFinder) error {
+	serviceNames := make([]string, 0, len(services))
+	for serviceName := range services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	owners := make(map[string]string)
+	rejections := make([]string, 0)
+
+	checkTarget := func(serviceName, kind, target string) {
+		owner, ok := owners[target]
+		if !ok {
+			var err error
+			if owner, err = findOwner(target); err != nil {
+				rejections = append(rejections, fmt.Sprintf("service=%s %s %s not found", serviceName, kind, target))
+				return
+			}
+
+			owners[target] = owner
+		}
+
+		if owner != user.Username {
+			rejections = append(rejections, fmt.Sprintf("service=%s %s %s is not yours", serviceName, kind, target))
+		}
+	}
+
+	for _, serviceName := range serviceNames {
+		service := services[serviceName]
+
+		for _, link := range service.Links {
+			if target := getLinkTarget(link); target != "" {
+				if _, ok := services[target]; !ok {
+					checkTarget(serviceName, "link", target)
+				}
+			}
+		}
+
+		for _, link := range service.ExternalLinks {
+			checkTarget(serviceName, "external link", getLinkTarget(link))
+		}
+	}
+
+	if len(rejections) > 0 {
+		return errors.New("invalid links: %s", strings.Join(rejections, ", "))
+	}
+
+	return nil
+}
+
+func (a *App) findContainerOwner(ctx context.Context, user *model.User) ownerFinder {
+	return func(containerName string) (string, error) {
+		container, err := a.dockerApp.InspectContainer(ctx, containerName)
+		if err != nil {
+			return "", err
+		}
+
+		if container.Config == nil {
+			return "", nil
+		}
+
+		if a.dockerApp.IsOwner(user, container.Config.Labels) {
+			return user.Username, nil
+		}
+
+		return container.Config.Labels[commons.OwnerLabel], nil
+	}
+}
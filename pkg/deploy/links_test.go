@@ -0,0 +1,67 @@
+package deploy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+)
+
+func TestCheckLinks(t *testing.T) {
+	var cases = []struct {
+		intention string
+		services  map[string]dockerComposeService
+		want      string
+	}{
+		{
+			"should accept links to services of same app",
+			map[string]dockerComposeService{
+				"api": {Links: []string{"db:database"}},
+				"db":  {},
+			},
+			"",
+		},
+		{
+			"should accept links to owned containers",
+			map[string]dockerComposeService{
+				"api": {Links: []string{"cache"}, ExternalLinks: []string{"other_db:db"}},
+			},
+			"",
+		},
+		{
+			"should reject links to containers of other users or unknown",
+			map[string]dockerComposeService{
+				"api":    {ExternalLinks: []string{"admin_db:db", "unknown"}},
+				"worker": {Links: []string{"admin_db"}},
+			},
+			"invalid links: service=api external link admin_db is not yours, service=api external link unknown not found, service=worker link admin_db is not yours",
+		},
+	}
+
+	owners := map[string]string{
+		"cache":    "test",
+		"other_db": "test",
+		"admin_db": "admin",
+	}
+
+	findOwner := func(containerName string) (string, error) {
+		if owner, ok := owners[containerName]; ok {
+			return owner, nil
+		}
+
+		return "", errors.New("not found")
+	}
+
+	user := model.NewUser("0", "test", "", "")
+
+	for _, testCase := range cases {
+		result := ""
+		if err := checkLinks(user, testCase.services, findOwner); err != nil {
+			result = err.Error()
+		}
+
+		if result != testCase.want {
+			t.Errorf("%s\ncheckLinks(%+v) = %v, want %v", testCase.intention, testCase.services, result, testCase.want)
+		}
+	}
+}
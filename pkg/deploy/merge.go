@@ -0,0 +1,290 @@
+package deploy
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ViBiOh/httputils/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	servicesKey = "services"
+	yamlIndent  = 2
+)
+
+var (
+	concatenatedFields = map[string]bool{
+		"dns":            true,
+		"dns_search":     true,
+		"expose":         true,
+		"external_links": true,
+		"links":          true,
+		"ports":          true,
+		"tmpfs":          true,
+	}
+
+	mappingFields = map[string]bool{
+		"environment": true,
+		"labels":      true,
+	}
+
+	mountFields = map[string]bool{
+		"devices": true,
+		"volumes": true,
+	}
+)
+
+func unmarshalNode(content []byte) (*yaml.Node, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	return document.Content[0], nil
+}
+
+func marshalNode(node *yaml.Node) ([]byte, error) {
+	var output bytes.Buffer
+
+	encoder := yaml.NewEncoder(&output)
+	encoder.SetIndent(yamlIndent)
+
+	if err := encoder.Encode(node); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return output.Bytes(), nil
+}
+
+func mergeComposeFiles(composeFiles [][]byte) ([]byte, error) {
+	if len(composeFiles) == 1 {
+		return composeFiles[0], nil
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+	for index, composeFile := range composeFiles {
+		content, err := unmarshalNode(composeFile)
+		if err != nil {
+			return nil, errors.New("compose file #%d: %v", index+1, err)
+		}
+
+		if content == nil {
+			continue
+		}
+
+		if content.Kind != yaml.MappingNode {
+			return nil, errors.New("compose file #%d: content is not a mapping", index+1)
+		}
+
+		for pair := 0; pair < len(content.Content); pair += 2 {
+			key, value := content.Content[pair], content.Content[pair+1]
+
+			if key.Value == servicesKey {
+				setMappingValue(merged, key, mergeServices(getMappingValue(merged, key.Value), value))
+			} else {
+				setMappingValue(merged, key, mergeValues(getMappingValue(merged, key.Value), value))
+			}
+		}
+	}
+
+	return marshalNode(merged)
+}
+
+func getMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for pair := 0; pair < len(mapping.Content); pair += 2 {
+		if mapping.Content[pair].Value == key {
+			return mapping.Content[pair+1]
+		}
+	}
+
+	return nil
+}
+
+func setMappingValue(mapping *yaml.Node, key, value *yaml.Node) {
+	for pair := 0; pair < len(mapping.Content); pair += 2 {
+		if mapping.Content[pair].Value == key.Value {
+			mapping.Content[pair+1] = value
+			return
+		}
+	}
+
+	mapping.Content = append(mapping.Content, key, value)
+}
+
+func copyMapping(node *yaml.Node) *yaml.Node {
+	output := *node
+	output.Content = append([]*yaml.Node{}, node.Content...)
+
+	return &output
+}
+
+func isMapping(node *yaml.Node) bool {
+	return node != nil && node.Kind == yaml.MappingNode
+}
+
+func mergeValues(base, override *yaml.Node) *yaml.Node {
+	if !isMapping(base) || !isMapping(override) {
+		return override
+	}
+
+	output := copyMapping(base)
+
+	for pair := 0; pair < len(override.Content); pair += 2 {
+		key, value := override.Content[pair], override.Content[pair+1]
+		setMappingValue(output, key, mergeValues(getMappingValue(output, key.Value), value))
+	}
+
+	return output
+}
+
+func mergeServices(base, override *yaml.Node) *yaml.Node {
+	if !isMapping(base) || !isMapping(override) {
+		return mergeValues(base, override)
+	}
+
+	output := copyMapping(base)
+
+	for pair := 0; pair < len(override.Content); pair += 2 {
+		name, service := override.Content[pair], override.Content[pair+1]
+		setMappingValue(output, name, mergeService(getMappingValue(output, name.Value), service))
+	}
+
+	return output
+}
+
+func mergeService(base, override *yaml.Node) *yaml.Node {
+	if !isMapping(base) || !isMapping(override) {
+		return override
+	}
+
+	output := copyMapping(base)
+
+	for pair := 0; pair < len(override.Content); pair += 2 {
+		key, value := override.Content[pair], override.Content[pair+1]
+		previous := getMappingValue(output, key.Value)
+
+		switch {
+		case concatenatedFields[key.Value]:
+			setMappingValue(output, key, concatenateLists(previous, value))
+		case mappingFields[key.Value]:
+			setMappingValue(output, key, mergeValues(toMapping(previous), toMapping(value)))
+		case mountFields[key.Value]:
+			setMappingValue(output, key, mergeMounts(previous, value))
+		default:
+			setMappingValue(output, key, mergeValues(previous, value))
+		}
+	}
+
+	return output
+}
+
+func toList(node *yaml.Node) []*yaml.Node {
+	switch {
+	case node == nil:
+		return nil
+	case node.Kind == yaml.SequenceNode:
+		return node.Content
+	default:
+		return []*yaml.Node{node}
+	}
+}
+
+func concatNodes(base, override *yaml.Node) []*yaml.Node {
+	output := append([]*yaml.Node{}, toList(base)...)
+	return append(output, toList(override)...)
+}
+
+func getNodeKey(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+
+	content, err := marshalNode(node)
+	if err != nil {
+		return ""
+	}
+
+	return string(content)
+}
+
+func concatenateLists(base, override *yaml.Node) *yaml.Node {
+	output := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	seen := make(map[string]bool)
+
+	for _, item := range concatNodes(base, override) {
+		key := getNodeKey(item)
+		if !seen[key] {
+			seen[key] = true
+			output.Content = append(output.Content, item)
+		}
+	}
+
+	return output
+}
+
+func newStringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func toMapping(node *yaml.Node) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return node
+	}
+
+	output := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, item := range node.Content {
+		parts := strings.SplitN(item.Value, "=", 2)
+		if len(parts) == 2 {
+			setMappingValue(output, newStringNode(parts[0]), newStringNode(parts[1]))
+		} else {
+			setMappingValue(output, newStringNode(parts[0]), &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"})
+		}
+	}
+
+	return output
+}
+
+func getMountTarget(mount *yaml.Node) string {
+	if isMapping(mount) {
+		if target := getMappingValue(mount, "target"); target != nil {
+			return target.Value
+		}
+
+		return ""
+	}
+
+	parts := strings.Split(mount.Value, colonSeparator)
+	if len(parts) > 1 {
+		return parts[1]
+	}
+
+	return parts[0]
+}
+
+func mergeMounts(base, override *yaml.Node) *yaml.Node {
+	output := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	indexes := make(map[string]int)
+
+	for _, mount := range concatNodes(base, override) {
+		target := getMountTarget(mount)
+
+		if index, ok := indexes[target]; ok {
+			output.Content[index] = mount
+		} else {
+			indexes[target] = len(output.Content)
+			output.Content = append(output.Content, mount)
+		}
+	}
+
+	return output
+}
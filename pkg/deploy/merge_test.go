@@ -0,0 +1,151 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestMergeComposeFiles(t *testing.T) {
+	var cases = []struct {
+		intention    string
+		composeFiles []string
+		want         string
+	}{
+		{
+			"should keep single file untouched",
+			[]string{"version: '2'"},
+			"version: '2'",
+		},
+		{
+			"should replace single values and merge mappings",
+			[]string{
+				`
+version: '2.1'
+services:
+  api:
+    image: vibioh/api
+    command: ["-port", "1080"]
+    environment:
+      ENV: dev
+      DEBUG: 'true'
+    labels:
+    - traefik.port=1080
+  db:
+    image: postgres
+`,
+				`
+services:
+  api:
+    image: vibioh/api:1.0.0
+    command: ["-port", "8080"]
+    environment:
+    - ENV=prod
+    labels:
+      traefik.frontend.rule: 'Host: api.vibioh.fr'
+`,
+			},
+			`
+version: '2.1'
+services:
+  api:
+    image: vibioh/api:1.0.0
+    command: ["-port", "8080"]
+    environment:
+      ENV: prod
+      DEBUG: 'true'
+    labels:
+      traefik.port: '1080'
+      traefik.frontend.rule: 'Host: api.vibioh.fr'
+  db:
+    image: postgres
+`,
+		},
+		{
+			"should concatenate multi-value fields and merge mounts by target",
+			[]string{
+				`
+services:
+  api:
+    dns: [8.8.8.8]
+    volumes:
+    - /data:/data
+    - /logs:/var/log:ro
+`,
+				`
+services:
+  api:
+    dns: [8.8.8.8, 1.1.1.1]
+    volumes:
+    - /srv/logs:/var/log
+`,
+			},
+			`
+services:
+  api:
+    dns: [8.8.8.8, 1.1.1.1]
+    volumes:
+    - /data:/data
+    - /srv/logs:/var/log
+`,
+		},
+	}
+
+	for _, testCase := range cases {
+		composeFiles := make([][]byte, 0, len(testCase.composeFiles))
+		for _, composeFile := range testCase.composeFiles {
+			composeFiles = append(composeFiles, []byte(composeFile))
+		}
+
+		rawResult, err := mergeComposeFiles(composeFiles)
+		if err != nil {
+			t.Errorf("%s\nmergeComposeFiles(%v) = %v", testCase.intention, testCase.composeFiles, err)
+			continue
+		}
+
+		var result, want interface{}
+		if err := yaml.Unmarshal(rawResult, &result); err != nil {
+			t.Errorf("%s\nmergeComposeFiles(%v) = invalid yaml %v", testCase.intention, testCase.composeFiles, err)
+			continue
+		}
+
+		if err := yaml.Unmarshal([]byte(testCase.want), &want); err != nil {
+			t.Errorf("%s\ninvalid wanted yaml %v", testCase.intention, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("%s\nmergeComposeFiles(%v) = %s, want %s", testCase.intention, testCase.composeFiles, rawResult, testCase.want)
+		}
+	}
+}
+
+func TestMergeComposeFilesKeepScalars(t *testing.T) {
+	var cases = []struct {
+		intention    string
+		composeFiles []string
+		want         string
+	}{
+		{
+			"should keep yaml 1.1 scalars as written",
+			[]string{
+				"services:\n  api:\n    image: vibioh/api\n    restart: no\n    environment:\n      VERSION: 1.10\n      DEBUG: on\n",
+				"services:\n  api:\n    cpu_shares: 512\n    environment:\n      - ENV=prod\n",
+			},
+			"services:\n  api:\n    image: vibioh/api\n    restart: no\n    environment:\n      VERSION: 1.10\n      DEBUG: on\n      ENV: prod\n    cpu_shares: 512\n",
+		},
+	}
+
+	for _, testCase := range cases {
+		composeFiles := make([][]byte, 0, len(testCase.composeFiles))
+		for _, composeFile := range testCase.composeFiles {
+			composeFiles = append(composeFiles, []byte(composeFile))
+		}
+
+		result, err := mergeComposeFiles(composeFiles)
+		if err != nil || string(result) != testCase.want {
+			t.Errorf("%s\nmergeComposeFiles(%v) = (%s, %v), want %s", testCase.intention, testCase.composeFiles, result, err, testCase.want)
+		}
+	}
+}
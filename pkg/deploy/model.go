@@ -1,40 +1,274 @@
 package deploy
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	healthcheckNone  = "NONE"
+	healthcheckCmd   = "CMD"
+	healthcheckShell = "CMD-SHELL"
+)
 
 var errHealthCheckFailed = errors.New("health check failed")
 
+type dockerComposeHealthcheckTest []string
+
+func (t *dockerComposeHealthcheckTest) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*t = []string{healthcheckShell, value}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+
+	*t = list
+	return nil
+}
+
 type dockerComposeHealthcheck struct {
-	Test     []string
-	Interval string
-	Timeout  string
-	Retries  int
+	Test        dockerComposeHealthcheckTest
+	Interval    string
+	Timeout     string
+	StartPeriod string `yaml:"start_period"`
+	Retries     int
+	Disable     bool
+}
+
+type dockerComposeFileReference struct {
+	Source string
+	Target string
+	UID    string
+	GID    string
+	Mode   uint32
+}
+
+func (s *dockerComposeFileReference) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var source string
+	if err := unmarshal(&source); err == nil {
+		s.Source = source
+		return nil
+	}
+
+	type plain dockerComposeFileReference
+	return unmarshal((*plain)(s))
+}
+
+type dockerComposeEnvironment map[string]*string
+
+func (e *dockerComposeEnvironment) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		values := make(map[string]*string)
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+
+		*e = values
+		return nil
+	}
+
+	values := make(dockerComposeEnvironment, len(list))
+	for _, item := range list {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) == 2 {
+			values[parts[0]] = &parts[1]
+		} else {
+			values[parts[0]] = nil
+		}
+	}
+
+	*e = values
+	return nil
+}
+
+type dockerComposeStringList []string
+
+func (l *dockerComposeStringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*l = []string{value}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+type dockerComposeMapping map[string]string
+
+func (m *dockerComposeMapping) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		values := make(map[string]string)
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+
+		*m = values
+		return nil
+	}
+
+	values := make(dockerComposeMapping, len(list))
+	for _, item := range list {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return errors.New("invalid mapping entry " + item)
+		}
+
+		values[parts[0]] = parts[1]
+	}
+
+	*m = values
+	return nil
+}
+
+type dockerComposeCommand []string
+
+func (c *dockerComposeCommand) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		words, err := shellSplit(value)
+		if err != nil {
+			return err
+		}
+
+		*c = words
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+
+	*c = list
+	return nil
+}
+
+type dockerComposeUlimit struct {
+	Soft int64
+	Hard int64
+}
+
+func (u *dockerComposeUlimit) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value int64
+	if err := unmarshal(&value); err == nil {
+		u.Soft = value
+		u.Hard = value
+		return nil
+	}
+
+	type plain dockerComposeUlimit
+	return unmarshal((*plain)(u))
+}
+
+type dockerComposeLogging struct {
+	Driver  string
+	Options map[string]string
+}
+
+type dockerComposeServiceNetwork struct {
+	Aliases []string
+}
+
+type dockerComposeServiceNetworks map[string]*dockerComposeServiceNetwork
+
+func (n *dockerComposeServiceNetworks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		values := make(map[string]*dockerComposeServiceNetwork)
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+
+		*n = values
+		return nil
+	}
+
+	values := make(dockerComposeServiceNetworks, len(list))
+	for _, name := range list {
+		values[name] = nil
+	}
+
+	*n = values
+	return nil
+}
+
+type dockerComposeDashboard struct {
+	EnvSecrets map[string]string `yaml:"env_secrets"`
+}
+
+type dockerComposeRateLimit struct {
+	Average int
+	Burst   int
+	Period  string
+}
+
+type dockerComposeExpose struct {
+	Hostname   string
+	Port       int
+	TLS        bool
+	RateLimit  *dockerComposeRateLimit `yaml:"rate_limit"`
+	PathPrefix string                  `yaml:"path_prefix"`
 }
 
 type dockerComposeService struct {
-	Image         string
-	Command       []string
-	Environment   map[string]string
-	Labels        map[string]string
-	Ports         []string
-	Links         []string
-	ExternalLinks []string `yaml:"external_links"`
-	Volumes       []string
-	DNS           []string
-	CapAdd        []string `yaml:"cap_add"`
-	SecurityOpt   []string `yaml:"security_opt"`
-	Hostname      string
-	User          string
-	GroupAdd      []string `yaml:"group_add"`
-	Healthcheck   *dockerComposeHealthcheck
-	ReadOnly      bool  `yaml:"read_only"`
-	CPUShares     int64 `yaml:"cpu_shares"`
-	MemoryLimit   int64 `yaml:"mem_limit"`
+	Image           string
+	Command         dockerComposeCommand
+	Entrypoint      dockerComposeCommand
+	WorkingDir      string `yaml:"working_dir"`
+	StopSignal      string `yaml:"stop_signal"`
+	StopGracePeriod string `yaml:"stop_grace_period"`
+	Ulimits         map[string]dockerComposeUlimit
+	Sysctls         dockerComposeMapping
+	Init            *bool
+	ShmSize         string `yaml:"shm_size"`
+	Restart         string
+	Logging         *dockerComposeLogging
+	Environment     dockerComposeEnvironment
+	EnvFile         dockerComposeStringList `yaml:"env_file"`
+	Labels          map[string]string
+	Ports           []string
+	Links           []string
+	ExternalLinks   []string `yaml:"external_links"`
+	Networks        dockerComposeServiceNetworks
+	Volumes         []string
+	DNS             []string
+	CapAdd          []string `yaml:"cap_add"`
+	SecurityOpt     []string `yaml:"security_opt"`
+	Hostname        string
+	User            string
+	GroupAdd        []string `yaml:"group_add"`
+	Healthcheck     *dockerComposeHealthcheck
+	ReadOnly        *bool `yaml:"read_only"`
+	CPUShares       int64 `yaml:"cpu_shares"`
+	MemoryLimit     int64 `yaml:"mem_limit"`
+	Secrets         []dockerComposeFileReference
+	Configs         []dockerComposeFileReference
+	Dashboard       *dockerComposeDashboard `yaml:"x-dashboard"`
+	Expose          *dockerComposeExpose    `yaml:"x-expose"`
+}
+
+type dockerComposeConfig struct {
+	File    string
+	Content string
 }
 
 type dockerCompose struct {
 	Version  string
 	Services map[string]dockerComposeService
+	Configs  map[string]dockerComposeConfig
 }
 
 type deployedService struct {
@@ -42,9 +276,12 @@ type deployedService struct {
 	FullName    string   `json:"fullname"`
 	ContainerID string   `json:"containerId"`
 	ImageName   string   `json:"imageName"`
+	ImageDigest string   `json:"imageDigest"`
+	ImageTag    string   `json:"imageTag"`
 	Logs        []string `json:"logs"`
 	HealthLogs  []string `json:"healthLogs"`
 	State       string   `json:"state"`
+	hostnames   []string
 }
 
 type deployNotification struct {
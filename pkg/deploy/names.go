@@ -0,0 +1,80 @@
+package deploy
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	maxAppNameLength       = 32
+	maxContainerNameLength = 63
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]*[a-z0-9])?$`)
+
+func checkName(kind, name string, maxLength int) error {
+	if len(name) > maxLength {
+		return errors.New("%s name `%s` is longer than %d characters", kind, name, maxLength)
+	}
+
+	if !namePattern.MatchString(name) {
+		return errors.New("%s name `%s` must contain only lowercase letters, digits and hyphens, and start and end with a letter or a digit", kind, name)
+	}
+
+	return nil
+}
+
+func checkAppName(user *model.User, appName string, reservedApps []string) error {
+	if err := checkName("app", appName, maxAppNameLength); err != nil {
+		return err
+	}
+
+	if docker.IsAdmin(user) {
+		return nil
+	}
+
+	for _, reservedApp := range reservedApps {
+		if appName == reservedApp {
+			return errors.New("app name `%s` is reserved", appName)
+		}
+	}
+
+	return nil
+}
+
+func (a *App) isValidAppName(w http.ResponseWriter, user *model.User, appName string) bool {
+	if err := checkAppName(user, appName, a.reservedApps); err != nil {
+		httperror.BadRequest(w, err)
+		return false
+	}
+
+	return true
+}
+
+func checkServicesNames(appName string, composeFile []byte) error {
+	var compose struct {
+		Services map[string]interface{}
+	}
+
+	if err := yaml.Unmarshal(composeFile, &compose); err != nil {
+		return errors.WithStack(err)
+	}
+
+	for serviceName := range compose.Services {
+		if err := checkName("service", serviceName, maxContainerNameLength); err != nil {
+			return err
+		}
+
+		if fullName := getServiceFullName(appName, serviceName); len(fullName) > maxContainerNameLength {
+			return errors.New("container name `%s` is longer than %d characters", fullName, maxContainerNameLength)
+		}
+	}
+
+	return nil
+}
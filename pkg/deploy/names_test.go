@@ -0,0 +1,154 @@
+package deploy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+)
+
+func TestCheckAppName(t *testing.T) {
+	var cases = []struct {
+		intention string
+		user      *model.User
+		appName   string
+		want      string
+	}{
+		{
+			"should accept dns label",
+			model.NewUser("0", "test", "", ""),
+			"my-app2",
+			"",
+		},
+		{
+			"should reject uppercase and slash",
+			model.NewUser("0", "test", "", ""),
+			"My/App",
+			"app name `My/App` must contain only lowercase letters, digits and hyphens, and start and end with a letter or a digit",
+		},
+		{
+			"should reject trailing hyphen",
+			model.NewUser("0", "test", "", ""),
+			"app-",
+			"app name `app-` must contain only lowercase letters, digits and hyphens, and start and end with a letter or a digit",
+		},
+		{
+			"should reject long name",
+			model.NewUser("0", "test", "", ""),
+			strings.Repeat("a", 33),
+			fmt.Sprintf("app name `%s` is longer than 32 characters", strings.Repeat("a", 33)),
+		},
+		{
+			"should reject reserved name",
+			model.NewUser("0", "test", "", ""),
+			"dashboard",
+			"app name `dashboard` is reserved",
+		},
+		{
+			"should accept reserved name for admin",
+			model.NewUser("0", "admin", "", "admin"),
+			"dashboard",
+			"",
+		},
+	}
+
+	for _, testCase := range cases {
+		result := ""
+		if err := checkAppName(testCase.user, testCase.appName, []string{"dashboard"}); err != nil {
+			result = err.Error()
+		}
+
+		if result != testCase.want {
+			t.Errorf("%s\ncheckAppName(%s) = %v, want %v", testCase.intention, testCase.appName, result, testCase.want)
+		}
+	}
+}
+
+func TestCheckServicesNames(t *testing.T) {
+	var cases = []struct {
+		intention string
+		compose   string
+		want      string
+	}{
+		{
+			"should accept valid services",
+			"services:\n  api:\n    image: vibioh/api\n  front-end:\n    image: vibioh/front\n",
+			"",
+		},
+		{
+			"should reject underscore",
+			"services:\n  front_end:\n    image: vibioh/front\n",
+			"service name `front_end` must contain only lowercase letters, digits and hyphens, and start and end with a letter or a digit",
+		},
+		{
+			"should reject too long container name",
+			fmt.Sprintf("services:\n  %s:\n    image: vibioh/api\n", strings.Repeat("a", 50)),
+			fmt.Sprintf("container name `dashboard_%s_deploy` is longer than 63 characters", strings.Repeat("a", 50)),
+		},
+	}
+
+	for _, testCase := range cases {
+		result := ""
+		if err := checkServicesNames("dashboard", []byte(testCase.compose)); err != nil {
+			result = err.Error()
+		}
+
+		if result != testCase.want {
+			t.Errorf("%s\ncheckServicesNames(%s) = %v, want %v", testCase.intention, testCase.compose, result, testCase.want)
+		}
+	}
+}
+
+func TestAppNameRoutes(t *testing.T) {
+	app, clean := newTestApp(t, "[]")
+	defer clean()
+	app.reservedApps = []string{"dashboard"}
+
+	user := model.NewUser("0", "bob", "", "")
+
+	routes := map[string]func(http.ResponseWriter, *http.Request, string){
+		"redeploy": func(w http.ResponseWriter, r *http.Request, appName string) {
+			app.redeploy(w, r, user, appName)
+		},
+		"transfer": func(w http.ResponseWriter, r *http.Request, appName string) {
+			app.transfer(w, r, user, appName)
+		},
+		"variables": func(w http.ResponseWriter, r *http.Request, appName string) {
+			app.variablesHandler(w, r, user, appName)
+		},
+		"envs": func(w http.ResponseWriter, r *http.Request, appName string) {
+			app.envsHandler(w, r, user, appName)
+		},
+		"env": func(w http.ResponseWriter, r *http.Request, appName string) {
+			app.envHandler(w, r, user, appName, "prod.env")
+		},
+	}
+
+	var cases = []struct {
+		intention string
+		appName   string
+	}{
+		{
+			"should reject invalid app name",
+			"My_App",
+		},
+		{
+			"should reject reserved app name",
+			"dashboard",
+		},
+	}
+
+	for _, testCase := range cases {
+		for route, handler := range routes {
+			writer := httptest.NewRecorder()
+			handler(writer, httptest.NewRequest(http.MethodGet, "/", strings.NewReader("{}")), testCase.appName)
+
+			if writer.Code != http.StatusBadRequest {
+				t.Errorf("%s\n%s(%s) = %d, want %d", testCase.intention, route, testCase.appName, writer.Code, http.StatusBadRequest)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/docker/docker/api/types/network"
+)
+
+const (
+	defaultNetwork      = "default"
+	traefikLabelPrefix  = "traefik."
+	traefikNetworkLabel = "traefik.docker.network"
+)
+
+func hasTraefikLabels(labels map[string]string) bool {
+	for label := range labels {
+		if strings.HasPrefix(label, traefikLabelPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func usesAppNetwork(services map[string]dockerComposeService) bool {
+	for _, service := range services {
+		if len(service.Networks) == 0 {
+			return true
+		}
+
+		if _, ok := service.Networks[defaultNetwork]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *App) isNetworkAllowed(user *model.User, name string) bool {
+	if name == defaultNetwork || name == a.network || docker.IsAdmin(user) {
+		return true
+	}
+
+	for _, sharedNetwork := range a.sharedNetworks {
+		if sharedNetwork == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *App) getServiceNetworks(user *model.User, service *dockerComposeService) (dockerComposeServiceNetworks, error) {
+	networks := make(dockerComposeServiceNetworks)
+
+	if len(service.Networks) == 0 {
+		networks[defaultNetwork] = nil
+	}
+
+	for name, settings := range service.Networks {
+		if !a.isNetworkAllowed(user, name) {
+			return nil, errors.New("network %s is not allowed", name)
+		}
+
+		networks[name] = settings
+	}
+
+	if _, ok := networks[a.network]; !ok && hasTraefikLabels(service.Labels) {
+		networks[a.network] = nil
+	}
+
+	return networks, nil
+}
+
+func (a *App) getNetworksConfig(user *model.User, appName string, serviceName string, service *dockerComposeService) (string, map[string]*network.EndpointSettings, error) {
+	networks, err := a.getServiceNetworks(user, service)
+	if err != nil {
+		return "", nil, err
+	}
+
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_, withAppNetwork := networks[defaultNetwork]
+	if !withAppNetwork && len(service.Links) > 0 {
+		return "", nil, errors.New("links require %s network", defaultNetwork)
+	}
+
+	primary := ""
+	endpoints := make(map[string]*network.EndpointSettings, len(networks))
+
+	for _, name := range names {
+		endpoint := network.EndpointSettings{}
+		if settings := networks[name]; settings != nil {
+			endpoint.Aliases = append(endpoint.Aliases, settings.Aliases...)
+		}
+
+		if name == defaultNetwork {
+			name = docker.GetAppNetworkName(appName)
+			endpoint.Aliases = append([]string{serviceName}, endpoint.Aliases...)
+			addLinks(&endpoint, service.Links)
+			primary = name
+		} else {
+			addLinks(&endpoint, service.ExternalLinks)
+		}
+
+		if primary == "" {
+			primary = name
+		}
+
+		endpoints[name] = &endpoint
+	}
+
+	if len(endpoints) == 1 && withAppNetwork {
+		addLinks(endpoints[primary], service.ExternalLinks)
+	}
+
+	return primary, endpoints, nil
+}
@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/docker/docker/api/types/network"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestGetNetworksConfig(t *testing.T) {
+	var cases = []struct {
+		intention   string
+		service     string
+		user        *model.User
+		wantPrimary string
+		want        map[string]*network.EndpointSettings
+		wantErr     bool
+	}{
+		{
+			"should join app network only by default",
+			"{links: [db]}",
+			model.NewUser("0", "test", "", ""),
+			"app_default",
+			map[string]*network.EndpointSettings{
+				"app_default": {Aliases: []string{"api"}, Links: []string{"db:db"}},
+			},
+			false,
+		},
+		{
+			"should join traefik network with traefik labels",
+			"{labels: {traefik.frontend.rule: 'Host: api.vibioh.fr'}, external_links: [other_db:db]}",
+			model.NewUser("0", "test", "", ""),
+			"app_default",
+			map[string]*network.EndpointSettings{
+				"app_default": {Aliases: []string{"api"}},
+				"traefik":     {Links: []string{"other_db:db"}},
+			},
+			false,
+		},
+		{
+			"should join declared shared network only",
+			"{networks: {monitoring: {aliases: [metrics]}}}",
+			model.NewUser("0", "test", "", ""),
+			"monitoring",
+			map[string]*network.EndpointSettings{
+				"monitoring": {Aliases: []string{"metrics"}},
+			},
+			false,
+		},
+		{
+			"should reject unknown network",
+			"{networks: [backend]}",
+			model.NewUser("0", "test", "", ""),
+			"",
+			nil,
+			true,
+		},
+		{
+			"should accept any network for admin",
+			"{networks: [default, backend]}",
+			model.NewUser("0", "admin", "", "admin"),
+			"app_default",
+			map[string]*network.EndpointSettings{
+				"app_default": {Aliases: []string{"api"}},
+				"backend":     {},
+			},
+			false,
+		},
+	}
+
+	app := App{network: "traefik", sharedNetworks: []string{"monitoring"}}
+
+	for _, testCase := range cases {
+		var service dockerComposeService
+		if err := yaml.Unmarshal([]byte(testCase.service), &service); err != nil {
+			t.Errorf("%s\nyaml.Unmarshal(%s) = %v", testCase.intention, testCase.service, err)
+			continue
+		}
+
+		primary, result, err := app.getNetworksConfig(testCase.user, "app", "api", &service)
+
+		if testCase.wantErr {
+			if err == nil {
+				t.Errorf("%s\ngetNetworksConfig(%s) = (%s, %+v), want error", testCase.intention, testCase.service, primary, result)
+			}
+		} else if err != nil || primary != testCase.wantPrimary || !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetNetworksConfig(%s) = (%s, %+v, %v), want (%s, %+v, nil)", testCase.intention, testCase.service, primary, result, err, testCase.wantPrimary, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+)
+
+const latestTag = "latest"
+
+var privilegedPaths = []string{"/var/run/docker.sock", "/proc", "/sys", "/dev", "/etc", "/root"}
+
+type imagePolicy struct {
+	Profile              string            `json:"profile"`
+	Allow                []string          `json:"allow"`
+	Deny                 []string          `json:"deny"`
+	NoLatestEnvironments []string          `json:"noLatestEnvironments"`
+	DenyRoot             bool              `json:"denyRoot"`
+	DenyPrivileged       bool              `json:"denyPrivileged"`
+	AllowedSysctls       []string          `json:"allowedSysctls"`
+	AllowedUlimits       []string          `json:"allowedUlimits"`
+	Hardening            *hardeningProfile `json:"hardening"`
+}
+
+func loadImagePolicies(filename string) ([]imagePolicy, error) {
+	if strings.TrimSpace(filename) == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var policies []imagePolicy
+	if err := json.Unmarshal(content, &policies); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, policy := range policies {
+		if err := policy.Hardening.loadSeccomp(); err != nil {
+			return nil, err
+		}
+	}
+
+	return policies, nil
+}
+
+func getImagePolicy(policies []imagePolicy, user *model.User) *imagePolicy {
+	var defaultPolicy *imagePolicy
+
+	for index, policy := range policies {
+		if policy.Profile == "" {
+			if defaultPolicy == nil {
+				defaultPolicy = &policies[index]
+			}
+		} else if user.HasProfile(policy.Profile) {
+			return &policies[index]
+		}
+	}
+
+	return defaultPolicy
+}
+
+func matchPattern(patterns []string, repository string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(repository, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if pattern == repository {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isLatest(named reference.Named) bool {
+	if _, ok := named.(reference.Digested); ok {
+		return false
+	}
+
+	tagged, ok := reference.TagNameOnly(named).(reference.Tagged)
+	return ok && tagged.Tag() == latestTag
+}
+
+func (p *imagePolicy) checkReference(image string, environment string) error {
+	if p == nil {
+		return nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return errors.New("invalid image %s: %v", image, err)
+	}
+
+	repository := named.Name()
+
+	if matchPattern(p.Deny, repository) {
+		return errors.New("repository %s is denied", repository)
+	}
+
+	if len(p.Allow) > 0 && !matchPattern(p.Allow, repository) {
+		return errors.New("repository %s is not allowed", repository)
+	}
+
+	if environment != "" && isLatest(named) {
+		for _, noLatestEnvironment := range p.NoLatestEnvironments {
+			if noLatestEnvironment == environment {
+				return errors.New("tag %s is forbidden for %s environment", latestTag, environment)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isRootUser(user string) bool {
+	name := strings.SplitN(user, colonSeparator, 2)[0]
+	return name == "" || name == "0" || name == "root"
+}
+
+func isPrivilegedPath(path string) bool {
+	for _, privilegedPath := range privilegedPaths {
+		if path == privilegedPath || strings.HasPrefix(path, fmt.Sprintf("%s/", privilegedPath)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *imagePolicy) checkImage(image types.ImageInspect) error {
+	if p == nil || image.Config == nil {
+		return nil
+	}
+
+	if p.DenyRoot && isRootUser(image.Config.User) {
+		return errors.New("image runs as root")
+	}
+
+	if p.DenyPrivileged {
+		for volume := range image.Config.Volumes {
+			if isPrivilegedPath(volume) {
+				return errors.New("image declares privileged volume %s", volume)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *imagePolicy) checkServices(services map[string]dockerComposeService, environment string, tags tagOverrides) error {
+	serviceNames := make([]string, 0, len(services))
+	for serviceName := range services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	rejections := make([]string, 0)
+
+	for _, serviceName := range serviceNames {
+		if err := p.checkReference(getEffectiveImage(services[serviceName].Image, tags.get(serviceName)), environment); err != nil {
+			rejections = append(rejections, fmt.Sprintf("service=%s %v", serviceName, err))
+		}
+	}
+
+	if len(rejections) > 0 {
+		return errors.New("rejected by image policy: %s", strings.Join(rejections, ", "))
+	}
+
+	return nil
+}
+
+func (p *imagePolicy) checkServiceOptions(service dockerComposeService) []string {
+	var allowedSysctls, allowedUlimits []string
+	var hardening *hardeningProfile
+	if p != nil {
+		allowedSysctls = p.AllowedSysctls
+		allowedUlimits = p.AllowedUlimits
+		hardening = p.Hardening
+	}
+
+	rejections := hardening.checkService(service)
+	if rejections == nil {
+		rejections = make([]string, 0)
+	}
+
+	for sysctl := range service.Sysctls {
+		if !matchPattern(allowedSysctls, sysctl) {
+			rejections = append(rejections, fmt.Sprintf("sysctl %s is not allowed", sysctl))
+		}
+	}
+
+	for ulimit := range service.Ulimits {
+		if !matchPattern(allowedUlimits, ulimit) {
+			rejections = append(rejections, fmt.Sprintf("ulimit %s is not allowed", ulimit))
+		}
+	}
+
+	sort.Strings(rejections)
+
+	return rejections
+}
+
+func (p *imagePolicy) checkServicesOptions(services map[string]dockerComposeService) error {
+	serviceNames := make([]string, 0, len(services))
+	for serviceName := range services {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	rejections := make([]string, 0)
+
+	for _, serviceName := range serviceNames {
+		for _, rejection := range p.checkServiceOptions(services[serviceName]) {
+			rejections = append(rejections, fmt.Sprintf("service=%s %s", serviceName, rejection))
+		}
+	}
+
+	if len(rejections) > 0 {
+		return errors.New("rejected by policy: %s", strings.Join(rejections, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestGetImagePolicy(t *testing.T) {
+	policies := []imagePolicy{
+		{Profile: "admin"},
+		{Deny: []string{"docker.io/*"}},
+	}
+
+	var cases = []struct {
+		intention string
+		policies  []imagePolicy
+		user      *model.User
+		want      *imagePolicy
+	}{
+		{
+			"should handle no policy",
+			nil,
+			model.NewUser("0", "admin", "", "admin"),
+			nil,
+		},
+		{
+			"should find profile policy",
+			policies,
+			model.NewUser("0", "admin", "", "admin"),
+			&policies[0],
+		},
+		{
+			"should fallback to default policy",
+			policies,
+			model.NewUser("0", "guest", "", "guest"),
+			&policies[1],
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := getImagePolicy(testCase.policies, testCase.user); result != testCase.want {
+			t.Errorf("%s\ngetImagePolicy(%v, %v) = %v, want %v", testCase.intention, testCase.policies, testCase.user, result, testCase.want)
+		}
+	}
+}
+
+func TestCheckReference(t *testing.T) {
+	var cases = []struct {
+		intention   string
+		policy      *imagePolicy
+		image       string
+		environment string
+		want        string
+	}{
+		{
+			"should accept everything without policy",
+			nil,
+			"vibioh/dashboard",
+			"prod",
+			"",
+		},
+		{
+			"should reject denied repository",
+			&imagePolicy{Deny: []string{"docker.io/*"}},
+			"vibioh/dashboard",
+			"",
+			"repository docker.io/vibioh/dashboard is denied",
+		},
+		{
+			"should reject not allowed repository",
+			&imagePolicy{Allow: []string{"registry.vibioh.fr/*"}},
+			"vibioh/dashboard",
+			"",
+			"repository docker.io/vibioh/dashboard is not allowed",
+		},
+		{
+			"should accept allowed repository",
+			&imagePolicy{Allow: []string{"registry.vibioh.fr/*"}},
+			"registry.vibioh.fr/vibioh/dashboard:1.0.0",
+			"",
+			"",
+		},
+		{
+			"should reject implicit latest for named environment",
+			&imagePolicy{NoLatestEnvironments: []string{"prod"}},
+			"vibioh/dashboard",
+			"prod",
+			"tag latest is forbidden for prod environment",
+		},
+		{
+			"should accept latest for other environment",
+			&imagePolicy{NoLatestEnvironments: []string{"prod"}},
+			"vibioh/dashboard:latest",
+			"dev",
+			"",
+		},
+	}
+
+	for _, testCase := range cases {
+		result := ""
+		if err := testCase.policy.checkReference(testCase.image, testCase.environment); err != nil {
+			result = err.Error()
+		}
+
+		if result != testCase.want {
+			t.Errorf("%s\ncheckReference(%v, %v) = %v, want %v", testCase.intention, testCase.image, testCase.environment, result, testCase.want)
+		}
+	}
+}
+
+func TestCheckServices(t *testing.T) {
+	policy := &imagePolicy{NoLatestEnvironments: []string{"prod"}}
+	services := map[string]dockerComposeService{
+		"api": {Image: "vibioh/dashboard"},
+	}
+
+	var cases = []struct {
+		intention string
+		tags      tagOverrides
+		want      string
+	}{
+		{
+			"should reject implicit latest without override",
+			tagOverrides{},
+			"rejected by image policy: service=api tag latest is forbidden for prod environment",
+		},
+		{
+			"should check image with tag override",
+			tagOverrides{defaultTag: "abcd1234"},
+			"",
+		},
+		{
+			"should check image with service tag override",
+			tagOverrides{defaultTag: "latest", services: map[string]string{"api": "1.0.0"}},
+			"",
+		},
+	}
+
+	for _, testCase := range cases {
+		result := ""
+		if err := policy.checkServices(services, "prod", testCase.tags); err != nil {
+			result = err.Error()
+		}
+
+		if result != testCase.want {
+			t.Errorf("%s\ncheckServices(%+v) = %v, want %v", testCase.intention, testCase.tags, result, testCase.want)
+		}
+	}
+}
+
+func TestCheckImage(t *testing.T) {
+	var cases = []struct {
+		intention string
+		policy    *imagePolicy
+		image     types.ImageInspect
+		want      string
+	}{
+		{
+			"should accept root without policy",
+			&imagePolicy{},
+			types.ImageInspect{Config: &container.Config{}},
+			"",
+		},
+		{
+			"should reject implicit root",
+			&imagePolicy{DenyRoot: true},
+			types.ImageInspect{Config: &container.Config{}},
+			"image runs as root",
+		},
+		{
+			"should accept non root user",
+			&imagePolicy{DenyRoot: true},
+			types.ImageInspect{Config: &container.Config{User: "1000:0"}},
+			"",
+		},
+		{
+			"should reject privileged volume",
+			&imagePolicy{DenyPrivileged: true},
+			types.ImageInspect{Config: &container.Config{Volumes: map[string]struct{}{"/var/run/docker.sock": {}}}},
+			"image declares privileged volume /var/run/docker.sock",
+		},
+	}
+
+	for _, testCase := range cases {
+		result := ""
+		if err := testCase.policy.checkImage(testCase.image); err != nil {
+			result = err.Error()
+		}
+
+		if result != testCase.want {
+			t.Errorf("%s\ncheckImage(%v) = %v, want %v", testCase.intention, testCase.image, result, testCase.want)
+		}
+	}
+}
+
+func TestCheckServiceOptions(t *testing.T) {
+	var cases = []struct {
+		intention string
+		policy    *imagePolicy
+		service   dockerComposeService
+		want      []string
+	}{
+		{
+			"should accept service without options",
+			nil,
+			dockerComposeService{},
+			[]string{},
+		},
+		{
+			"should reject options without policy",
+			nil,
+			dockerComposeService{
+				Sysctls: dockerComposeMapping{"net.core.somaxconn": "1024"},
+				Ulimits: map[string]dockerComposeUlimit{"nofile": {Soft: 1024, Hard: 2048}},
+			},
+			[]string{"sysctl net.core.somaxconn is not allowed", "ulimit nofile is not allowed"},
+		},
+		{
+			"should accept allowed options",
+			&imagePolicy{AllowedSysctls: []string{"net.*"}, AllowedUlimits: []string{"nofile"}},
+			dockerComposeService{
+				Sysctls: dockerComposeMapping{"net.core.somaxconn": "1024", "kernel.shmmax": "1"},
+				Ulimits: map[string]dockerComposeUlimit{"nofile": {Soft: 1024, Hard: 2048}},
+			},
+			[]string{"sysctl kernel.shmmax is not allowed"},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := testCase.policy.checkServiceOptions(testCase.service); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ncheckServiceOptions(%+v) = %v, want %v", testCase.intention, testCase.service, result, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/auth"
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/httpjson"
+	"github.com/docker/docker/api/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const runningState = "running"
+
+type quota struct {
+	User          string `json:"user,omitempty"`
+	Profile       string `json:"profile,omitempty"`
+	MaxApps       int    `json:"maxApps"`
+	MaxContainers int    `json:"maxContainers"`
+	MaxMemory     int64  `json:"maxMemory"`
+	MaxCPUShares  int64  `json:"maxCPUShares"`
+}
+
+type quotaUsage struct {
+	Apps       int   `json:"apps"`
+	Containers int   `json:"containers"`
+	Memory     int64 `json:"memory"`
+	CPUShares  int64 `json:"cpuShares"`
+}
+
+type pendingUsage struct {
+	owner string
+	usage quotaUsage
+}
+
+type quotaReport struct {
+	Usage quotaUsage `json:"usage"`
+	Quota *quota     `json:"quota"`
+}
+
+func loadQuotas(filename string) ([]quota, error) {
+	if strings.TrimSpace(filename) == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var quotas []quota
+	if err := json.Unmarshal(content, &quotas); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return quotas, nil
+}
+
+// getQuota finds quota of owner, profiles being only known when user is the owner
+func getQuota(quotas []quota, owner string, user *model.User) *quota {
+	var profileQuota *quota
+	var defaultQuota *quota
+
+	for index, item := range quotas {
+		switch {
+		case item.User != "":
+			if item.User == owner {
+				return &quotas[index]
+			}
+		case item.Profile != "":
+			if profileQuota == nil && owner == user.Username && user.HasProfile(item.Profile) {
+				profileQuota = &quotas[index]
+			}
+		case defaultQuota == nil:
+			defaultQuota = &quotas[index]
+		}
+	}
+
+	if profileQuota != nil {
+		return profileQuota
+	}
+
+	return defaultQuota
+}
+
+func (q *quota) check(usage quotaUsage) error {
+	if q == nil {
+		return nil
+	}
+
+	violations := make([]string, 0)
+
+	if q.MaxApps > 0 && usage.Apps > q.MaxApps {
+		violations = append(violations, fmt.Sprintf("%d apps exceed quota of %d", usage.Apps, q.MaxApps))
+	}
+
+	if q.MaxContainers > 0 && usage.Containers > q.MaxContainers {
+		violations = append(violations, fmt.Sprintf("%d containers exceed quota of %d", usage.Containers, q.MaxContainers))
+	}
+
+	if q.MaxMemory > 0 && usage.Memory > q.MaxMemory {
+		violations = append(violations, fmt.Sprintf("%d bytes of memory exceed quota of %d", usage.Memory, q.MaxMemory))
+	}
+
+	if q.MaxCPUShares > 0 && usage.CPUShares > q.MaxCPUShares {
+		violations = append(violations, fmt.Sprintf("%d cpu shares exceed quota of %d", usage.CPUShares, q.MaxCPUShares))
+	}
+
+	if len(violations) > 0 {
+		return errors.New("quota exceeded: %s", strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+func (u quotaUsage) add(other quotaUsage) quotaUsage {
+	u.Apps += other.Apps
+	u.Containers += other.Containers
+	u.Memory += other.Memory
+	u.CPUShares += other.CPUShares
+
+	return u
+}
+
+func (u quotaUsage) addServices(services map[string]dockerComposeService) quotaUsage {
+	if len(services) == 0 {
+		return u
+	}
+
+	u.Apps++
+
+	for _, service := range services {
+		cpuShares, memory := getServiceResources(&service)
+
+		u.Containers++
+		u.CPUShares += cpuShares
+		u.Memory += memory
+	}
+
+	return u
+}
+
+// getUsage computes usage of owner, deploying apps counting for their planned usage. It must be called with quotasMutex held
+func (a *App) getUsage(ctx context.Context, owner string, excludedApp string) (quotaUsage, error) {
+	usage := quotaUsage{}
+
+	containers, err := a.dockerApp.Docker.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return usage, errors.WithStack(err)
+	}
+
+	apps := make(map[string]bool)
+
+	for _, container := range containers {
+		appName := container.Labels[commons.AppLabel]
+		if a.dockerApp.GetAppOwnership(appName, container.Labels).Owner != owner || container.State != runningState || appName == excludedApp {
+			continue
+		}
+
+		if _, ok := a.pendingUsages[appName]; ok {
+			continue
+		}
+
+		infos, err := a.dockerApp.InspectContainer(ctx, container.ID)
+		if err != nil {
+			return usage, err
+		}
+
+		apps[appName] = true
+		usage.Containers++
+
+		if infos.HostConfig != nil {
+			usage.CPUShares += infos.HostConfig.CPUShares
+			usage.Memory += infos.HostConfig.Memory
+		}
+	}
+
+	usage.Apps = len(apps)
+
+	return a.addPendingUsages(usage, owner, excludedApp), nil
+}
+
+func (a *App) addPendingUsages(usage quotaUsage, owner string, excludedApp string) quotaUsage {
+	for appName, pending := range a.pendingUsages {
+		if pending.owner == owner && appName != excludedApp {
+			usage = usage.add(pending.usage)
+		}
+	}
+
+	return usage
+}
+
+// reserveQuota checks usage planned by compose file against owner's quota, and holds it until deploy ends
+func (a *App) reserveQuota(ctx context.Context, w http.ResponseWriter, user *model.User, owner string, appName string, composeFile []byte) bool {
+	ownerQuota := getQuota(a.quotas, owner, user)
+	if ownerQuota == nil || docker.IsAdmin(user) {
+		return true
+	}
+
+	compose := dockerCompose{}
+	if err := yaml.Unmarshal(composeFile, &compose); err != nil {
+		httperror.InternalServerError(w, errors.WithStack(err))
+		return false
+	}
+
+	planned := quotaUsage{}.addServices(compose.Services)
+
+	a.quotasMutex.Lock()
+	defer a.quotasMutex.Unlock()
+
+	usage, err := a.getUsage(ctx, owner, appName)
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return false
+	}
+
+	if err := ownerQuota.check(usage.add(planned)); err != nil {
+		http.Error(w, fmt.Sprintf("user=%s app=%s owner=%s %v", user.Username, appName, owner, err), http.StatusForbidden)
+		return false
+	}
+
+	if a.pendingUsages == nil {
+		a.pendingUsages = make(map[string]pendingUsage)
+	}
+	a.pendingUsages[appName] = pendingUsage{owner: owner, usage: planned}
+
+	return true
+}
+
+func (a *App) releaseQuota(appName string) {
+	a.quotasMutex.Lock()
+	defer a.quotasMutex.Unlock()
+
+	delete(a.pendingUsages, appName)
+}
+
+// QuotaHandler for quota request. Should be use with net/http
+func (a *App) QuotaHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			httperror.BadRequest(w, errors.New("user not provided"))
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := commons.GetCtx(r.Context())
+		defer cancel()
+
+		a.quotasMutex.Lock()
+		usage, err := a.getUsage(ctx, user.Username, "")
+		a.quotasMutex.Unlock()
+
+		if err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		if err := httpjson.ResponseJSON(w, http.StatusOK, quotaReport{Usage: usage, Quota: getQuota(a.quotas, user.Username, user)}, httpjson.IsPretty(r)); err != nil {
+			httperror.InternalServerError(w, err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+)
+
+func TestGetQuota(t *testing.T) {
+	quotas := []quota{
+		{MaxApps: 1},
+		{Profile: "team", MaxApps: 5},
+		{User: "vibioh", MaxApps: 10},
+	}
+
+	var cases = []struct {
+		intention string
+		owner     string
+		user      *model.User
+		want      *quota
+	}{
+		{
+			"should find user quota first",
+			"vibioh",
+			model.NewUser("0", "vibioh", "", "team"),
+			&quotas[2],
+		},
+		{
+			"should find profile quota",
+			"test",
+			model.NewUser("0", "test", "", "team"),
+			&quotas[1],
+		},
+		{
+			"should fallback to default quota",
+			"test",
+			model.NewUser("0", "test", "", ""),
+			&quotas[0],
+		},
+		{
+			"should find quota of owner for team member",
+			"vibioh",
+			model.NewUser("0", "test", "", "team"),
+			&quotas[2],
+		},
+		{
+			"should not use member profile for owner",
+			"bob",
+			model.NewUser("0", "test", "", "team"),
+			&quotas[0],
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := getQuota(quotas, testCase.owner, testCase.user); result != testCase.want {
+			t.Errorf("%s\ngetQuota(%s, %+v) = %+v, want %+v", testCase.intention, testCase.owner, testCase.user, result, testCase.want)
+		}
+	}
+}
+
+func TestQuotaCheck(t *testing.T) {
+	var cases = []struct {
+		intention string
+		quota     *quota
+		usage     quotaUsage
+		want      string
+	}{
+		{
+			"should accept without quota",
+			nil,
+			quotaUsage{Apps: 100},
+			"",
+		},
+		{
+			"should accept unlimited values",
+			&quota{MaxApps: 2},
+			quotaUsage{Apps: 2, Containers: 100, Memory: 1 << 40, CPUShares: 10000},
+			"",
+		},
+		{
+			"should list every exceeded limit",
+			&quota{MaxApps: 2, MaxContainers: 4, MaxMemory: 1024, MaxCPUShares: 512},
+			quotaUsage{Apps: 3, Containers: 4, Memory: 2048, CPUShares: 1024},
+			"quota exceeded: 3 apps exceed quota of 2, 2048 bytes of memory exceed quota of 1024, 1024 cpu shares exceed quota of 512",
+		},
+	}
+
+	for _, testCase := range cases {
+		result := ""
+		if err := testCase.quota.check(testCase.usage); err != nil {
+			result = err.Error()
+		}
+
+		if result != testCase.want {
+			t.Errorf("%s\ncheck(%+v) = %v, want %v", testCase.intention, testCase.usage, result, testCase.want)
+		}
+	}
+}
+
+func TestQuotaUsageAddServices(t *testing.T) {
+	var cases = []struct {
+		intention string
+		usage     quotaUsage
+		services  map[string]dockerComposeService
+		want      quotaUsage
+	}{
+		{
+			"should not count empty app",
+			quotaUsage{Apps: 1, Containers: 1},
+			nil,
+			quotaUsage{Apps: 1, Containers: 1},
+		},
+		{
+			"should count services with default and capped resources",
+			quotaUsage{Apps: 1, Containers: 1, Memory: minMemory, CPUShares: defaultCPUShares},
+			map[string]dockerComposeService{
+				"api":   {CPUShares: 256, MemoryLimit: maxMemory * 2},
+				"front": {},
+			},
+			quotaUsage{Apps: 2, Containers: 3, Memory: 2*minMemory + maxMemory, CPUShares: 2*defaultCPUShares + 256},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := testCase.usage.addServices(testCase.services); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\naddServices(%+v) = %+v, want %+v", testCase.intention, testCase.services, result, testCase.want)
+		}
+	}
+}
+
+func TestAddPendingUsages(t *testing.T) {
+	app := App{
+		pendingUsages: map[string]pendingUsage{
+			"blog": {owner: "bob", usage: quotaUsage{Apps: 1, Containers: 2, Memory: 128, CPUShares: 256}},
+			"api":  {owner: "bob", usage: quotaUsage{Apps: 1, Containers: 1, Memory: 64, CPUShares: 128}},
+			"www":  {owner: "alice", usage: quotaUsage{Apps: 1, Containers: 1, Memory: 32, CPUShares: 128}},
+		},
+	}
+
+	var cases = []struct {
+		intention   string
+		owner       string
+		excludedApp string
+		want        quotaUsage
+	}{
+		{
+			"should add deploying apps of owner",
+			"bob",
+			"",
+			quotaUsage{Apps: 3, Containers: 4, Memory: 256, CPUShares: 512},
+		},
+		{
+			"should skip deploying app being checked",
+			"bob",
+			"blog",
+			quotaUsage{Apps: 2, Containers: 2, Memory: 128, CPUShares: 256},
+		},
+		{
+			"should ignore other owners",
+			"carol",
+			"",
+			quotaUsage{Apps: 1, Containers: 1, Memory: 64, CPUShares: 128},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := app.addPendingUsages(quotaUsage{Apps: 1, Containers: 1, Memory: 64, CPUShares: 128}, testCase.owner, testCase.excludedApp); result != testCase.want {
+			t.Errorf("%s\naddPendingUsages(%s, %s) = %+v, want %+v", testCase.intention, testCase.owner, testCase.excludedApp, result, testCase.want)
+		}
+	}
+}
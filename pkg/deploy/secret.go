@@ -0,0 +1,48 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/httputils/pkg/errors"
+)
+
+const defaultSecretsDirectory = "/run/secrets"
+
+func (a *App) getServiceSecrets(user *model.User, appName string, service *dockerComposeService) ([]string, []string, []containerFile, error) {
+	var environments, names []string
+	var files []containerFile
+
+	if service.Dashboard != nil {
+		for name := range service.Dashboard.EnvSecrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			value, err := a.secretApp.Get(a.dockerApp.GetAppOwner(user, appName), appName, service.Dashboard.EnvSecrets[name])
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			environments = append(environments, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+
+	for _, secret := range service.Secrets {
+		value, err := a.secretApp.Get(a.dockerApp.GetAppOwner(user, appName), appName, secret.Source)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		file, err := getReferencedFile(secret, []byte(value), defaultSecretsDirectory)
+		if err != nil {
+			return nil, nil, nil, errors.New("secret %s: %v", secret.Source, err)
+		}
+
+		files = append(files, file)
+	}
+
+	return environments, names, files, nil
+}
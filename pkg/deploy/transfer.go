@@ -0,0 +1,148 @@
+package deploy
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/dashboard/pkg/store"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/ViBiOh/httputils/pkg/request"
+)
+
+var transferRequest = regexp.MustCompile(`^/([^/]+)/transfer/?$`)
+
+func parseTransfer(r *http.Request) (docker.Ownership, error) {
+	var ownership docker.Ownership
+
+	payload, err := request.ReadBodyRequest(r)
+	if err != nil {
+		return ownership, err
+	}
+
+	if err := json.Unmarshal(payload, &ownership); err != nil {
+		return ownership, errors.WithStack(err)
+	}
+
+	ownership.Owner = strings.TrimSpace(ownership.Owner)
+	ownership.Team = strings.TrimSpace(ownership.Team)
+
+	return ownership, nil
+}
+
+func (a *App) moveAppData(appName string, from string, to string) error {
+	storeNames := []func(string, string) string{getComposeStoreName, getFilesStoreName, getRequestVariablesStoreName, getVariablesStoreName, getEnvsStoreName}
+
+	moves := make([]func(string, string) error, 0, len(storeNames)+1)
+	for _, getStoreName := range storeNames {
+		getName := getStoreName
+		moves = append(moves, func(from string, to string) error {
+			return a.storeApp.Rename(getName(from, appName), getName(to, appName))
+		})
+	}
+	moves = append(moves, func(from string, to string) error {
+		return a.secretApp.Move(appName, from, to)
+	})
+
+	for index, move := range moves {
+		if err := move(from, to); err != nil {
+			for _, rollback := range moves[:index] {
+				if rollbackErr := rollback(to, from); rollbackErr != nil {
+					logger.Error("app=%s %+v", appName, rollbackErr)
+				}
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *App) transfer(w http.ResponseWriter, r *http.Request, user *model.User, appName string) {
+	if !a.isValidAppName(w, user, appName) {
+		return
+	}
+
+	if !a.storeApp.Enabled() {
+		http.Error(w, store.ErrNotConfigured.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	if !a.dockerApp.Can(user, docker.DeployAction, appName) {
+		httperror.Forbidden(w)
+		return
+	}
+
+	ownership, err := parseTransfer(r)
+	if err != nil {
+		httperror.BadRequest(w, err)
+		return
+	}
+
+	containers, err := a.dockerApp.ListContainers(r.Context(), user, appName)
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	var labels map[string]string
+	for _, container := range containers {
+		if container.Labels[commons.AppLabel] == appName {
+			labels = container.Labels
+			break
+		}
+	}
+
+	current := a.dockerApp.GetAppOwnership(appName, labels)
+	if current.Owner == "" {
+		httperror.NotFound(w)
+		return
+	}
+
+	if !docker.IsAdmin(user) && current.Owner != user.Username {
+		httperror.Forbidden(w)
+		return
+	}
+
+	if ownership.Owner == "" {
+		ownership.Owner = current.Owner
+	}
+
+	if ownership.Team != "" && !a.dockerApp.HasTeam(ownership.Team) {
+		httperror.BadRequest(w, errors.New("team %s not found", ownership.Team))
+		return
+	}
+
+	if ownership.Owner != current.Owner {
+		if err := a.moveAppData(appName, current.Owner, ownership.Owner); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+	}
+
+	if err := a.dockerApp.SetAppOwnership(appName, ownership); err != nil {
+		if ownership.Owner != current.Owner {
+			if moveErr := a.moveAppData(appName, ownership.Owner, current.Owner); moveErr != nil {
+				logger.Error("app=%s %+v", appName, moveErr)
+			}
+		}
+
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	if err := a.transferHostnames(appName, ownership.Owner); err != nil {
+		logger.Error("user=%s, app=%s %+v", user.Username, appName, err)
+	}
+
+	logger.Info("user=%s, app=%s transferred to owner=%s team=%s", user.Username, appName, ownership.Owner, ownership.Team)
+	a.recordAction(user, transferAction, appName, map[string]string{"owner": ownership.Owner, "team": ownership.Team}, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,82 @@
+package deploy
+
+import (
+	"path"
+	"testing"
+)
+
+func TestMoveAppData(t *testing.T) {
+	getNames := func(owner string) []string {
+		return []string{
+			getComposeStoreName(owner, "blog"),
+			getVariablesStoreName(owner, "blog"),
+			getEnvsStoreName(owner, "blog"),
+			path.Join("secrets", owner, "blog.json"),
+		}
+	}
+
+	var cases = []struct {
+		intention string
+		blocked   string
+		want      string
+		wantErr   bool
+	}{
+		{
+			"should move all data to new owner",
+			"",
+			"bob",
+			false,
+		},
+		{
+			"should rollback moved data when a move fails",
+			getEnvsStoreName("bob", "blog"),
+			"alice",
+			true,
+		},
+		{
+			"should rollback moved data when secrets move fails",
+			path.Join("secrets", "bob", "blog.json"),
+			"alice",
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		app, clean := newTestApp(t, "[]")
+
+		for _, name := range getNames("alice") {
+			if err := app.storeApp.WriteFile(name, []byte(name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if testCase.blocked != "" {
+			// a file cannot replace a non-empty directory
+			if err := app.storeApp.WriteFile(path.Join(testCase.blocked, "blocked"), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := app.moveAppData("blog", "alice", "bob")
+
+		failed := false
+
+		if testCase.wantErr && err == nil {
+			failed = true
+		} else if !testCase.wantErr && err != nil {
+			failed = true
+		}
+
+		for index, name := range getNames(testCase.want) {
+			if content, _ := app.storeApp.ReadFile(name); string(content) != getNames("alice")[index] {
+				t.Errorf("%s\nmoveAppData() left %s with %#v, want %#v", testCase.intention, name, content, getNames("alice")[index])
+			}
+		}
+
+		clean()
+
+		if failed {
+			t.Errorf("%s\nmoveAppData() = %v, want error %t", testCase.intention, err, testCase.wantErr)
+		}
+	}
+}
@@ -3,17 +3,187 @@ package deploy
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/ViBiOh/auth/pkg/model"
 	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/dashboard/pkg/docker"
 	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/logger"
 	"github.com/ViBiOh/httputils/pkg/request"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 )
 
+const (
+	multipartMediaType = "multipart/form-data"
+	composeFormField   = "compose"
+	fileFormField      = "file"
+	maxMultipartMemory = 32 << 20
+
+	teamParam        = "team"
+	tagParam         = "tag"
+	tagServicePrefix = "tag."
+)
+
+var tagPattern = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+type tagOverrides struct {
+	defaultTag string
+	services   map[string]string
+}
+
+func (t tagOverrides) get(serviceName string) string {
+	if tag, ok := t.services[serviceName]; ok {
+		return tag
+	}
+
+	return t.defaultTag
+}
+
+func getTagOverrides(params url.Values, defaultTag string) (tagOverrides, error) {
+	overrides := tagOverrides{
+		defaultTag: defaultTag,
+		services:   make(map[string]string),
+	}
+
+	for key, values := range params {
+		if key != tagParam && !strings.HasPrefix(key, tagServicePrefix) {
+			continue
+		}
+
+		tag := strings.TrimSpace(values[0])
+		if !tagPattern.MatchString(tag) {
+			return overrides, errors.New("invalid tag `%s` for %s", tag, key)
+		}
+
+		if key == tagParam {
+			overrides.defaultTag = tag
+		} else {
+			overrides.services[strings.TrimPrefix(key, tagServicePrefix)] = tag
+		}
+	}
+
+	return overrides, nil
+}
+
+func getImageOverride(image, tag string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if !reference.IsNameOnly(named) {
+		return "", nil
+	}
+
+	tagged, err := reference.WithTag(named, tag)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return reference.FamiliarString(tagged), nil
+}
+
+func getEffectiveImage(image, tag string) string {
+	if tag == "" {
+		return image
+	}
+
+	if imageOverride, err := getImageOverride(image, tag); err == nil && imageOverride != "" {
+		return imageOverride
+	}
+
+	return image
+}
+
+func getImageTag(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+
+	if tagged, ok := reference.TagNameOnly(named).(reference.Tagged); ok {
+		return tagged.Tag()
+	}
+
+	return ""
+}
+
+func shellSplit(value string) ([]string, error) {
+	words := make([]string, 0)
+
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	escaped := false
+
+	for _, char := range value {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\\\"$`", char) {
+				word.WriteRune('\\')
+			}
+
+			word.WriteRune(char)
+			escaped = false
+		case char == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			} else {
+				word.WriteRune(char)
+			}
+		case char == '\'' || char == '"':
+			quote = char
+			inWord = true
+		case unicode.IsSpace(char):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(char)
+			inWord = true
+		}
+	}
+
+	if escaped || quote != 0 {
+		return nil, errors.New("unterminated quote or escape in `%s`", value)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func splitList(value string) []string {
+	output := make([]string, 0)
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			output = append(output, item)
+		}
+	}
+
+	return output
+}
+
 func healthyStatusFilters(filtersArgs *filters.Args, containersIds []string) {
 	filtersArgs.Add("event", "health_status: healthy")
 
@@ -23,39 +193,175 @@ func healthyStatusFilters(filtersArgs *filters.Args, containersIds []string) {
 }
 
 func hasHealthcheck(container *types.ContainerJSON) bool {
-	return container != nil && container.Config != nil && container.Config.Healthcheck != nil && len(container.Config.Healthcheck.Test) != 0
+	return container != nil && container.Config != nil && container.Config.Healthcheck != nil && len(container.Config.Healthcheck.Test) != 0 && container.Config.Healthcheck.Test[0] != healthcheckNone
 }
 
-func checkParams(r *http.Request, user *model.User) (string, []byte, error) {
+func getDeployTimeout(containers []*types.ContainerJSON) time.Duration {
+	var startPeriod time.Duration
+
+	for _, container := range containers {
+		if hasHealthcheck(container) && container.Config.Healthcheck.StartPeriod > startPeriod {
+			startPeriod = container.Config.Healthcheck.StartPeriod
+		}
+	}
+
+	if startPeriod > MaxStartPeriod {
+		startPeriod = MaxStartPeriod
+	}
+
+	return DeployTimeout + startPeriod
+}
+
+func readFormFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	content, err := ioutil.ReadAll(file)
+	if closeErr := file.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return content, nil
+}
+
+func readFormFiles(r *http.Request, field string) ([][]byte, error) {
+	contents := make([][]byte, 0)
+
+	for _, fileHeader := range r.MultipartForm.File[field] {
+		content, err := readFormFile(fileHeader)
+		if err != nil {
+			return nil, err
+		}
+
+		contents = append(contents, content)
+	}
+
+	return contents, nil
+}
+
+func readRequestFiles(r *http.Request) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	if r.MultipartForm == nil {
+		return files, nil
+	}
+
+	for _, fileHeader := range r.MultipartForm.File[fileFormField] {
+		content, err := readFormFile(fileHeader)
+		if err != nil {
+			return nil, err
+		}
+
+		files[path.Base(fileHeader.Filename)] = content
+	}
+
+	return files, nil
+}
+
+func readComposeFiles(r *http.Request) ([][]byte, error) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != multipartMediaType {
+		composeFile, err := request.ReadBodyRequest(r)
+		if err != nil || len(composeFile) == 0 {
+			return nil, err
+		}
+
+		return [][]byte{composeFile}, nil
+	}
+
+	if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return readFormFiles(r, composeFormField)
+}
+
+func checkParams(r *http.Request, user *model.User, reservedApps []string) (string, []byte, map[string][]byte, error) {
 	appName := strings.Trim(r.URL.Path, "/")
 
 	if user == nil {
-		return appName, nil, commons.ErrUserRequired
+		return appName, nil, nil, commons.ErrUserRequired
 	}
 
-	composeFile, err := request.ReadBodyRequest(r)
+	composeFiles, err := readComposeFiles(r)
 	if err != nil {
-		return appName, nil, err
+		return appName, nil, nil, err
 	}
 
-	if len(appName) == 0 || len(composeFile) == 0 {
-		return appName, nil, errors.New("app name and compose file are required")
+	if len(appName) == 0 || len(composeFiles) == 0 {
+		return appName, nil, nil, errors.New("app name and compose file are required")
 	}
 
-	return appName, composeFile, nil
+	if err := checkAppName(user, appName, reservedApps); err != nil {
+		return appName, nil, nil, err
+	}
+
+	composeFile, err := mergeComposeFiles(composeFiles)
+	if err != nil {
+		return appName, nil, nil, err
+	}
+
+	if err := checkServicesNames(appName, composeFile); err != nil {
+		return appName, nil, nil, err
+	}
+
+	files, err := readRequestFiles(r)
+	if err != nil {
+		return appName, nil, nil, err
+	}
+
+	return appName, composeFile, files, nil
 }
 
-func (a *App) checkRights(ctx context.Context, user *model.User, appName string) ([]types.Container, error) {
-	oldContainers, err := a.dockerApp.ListContainers(ctx, user, appName)
+func (a *App) checkRights(ctx context.Context, user *model.User, appName, team string) ([]types.Container, docker.Ownership, error) {
+	var ownership docker.Ownership
+
+	containers, err := a.dockerApp.ListContainers(ctx, user, appName)
 	if err != nil {
-		return nil, err
+		return nil, ownership, err
+	}
+
+	var labels map[string]string
+	oldContainers := make([]types.Container, 0, len(containers))
+
+	for _, container := range containers {
+		containerApp := container.Labels[commons.AppLabel]
+
+		if containerApp == appName {
+			if labels == nil {
+				labels = container.Labels
+			}
+		} else if a.dockerApp.GetAppOwnership(containerApp, container.Labels).Owner != user.Username {
+			continue
+		}
+
+		oldContainers = append(oldContainers, container)
 	}
 
-	if len(oldContainers) > 0 && oldContainers[0].Labels[commons.OwnerLabel] != user.Username {
-		return nil, errors.New("user=%s app=%s application is not yours", user.Username, appName)
+	ownership = a.dockerApp.GetAppOwnership(appName, labels)
+	if ownership.Owner == "" {
+		ownership.Owner = user.Username
+	} else if !a.dockerApp.IsOwnedBy(user, ownership) {
+		return nil, ownership, errors.New("user=%s app=%s application is not yours", user.Username, appName)
 	}
 
-	return oldContainers, nil
+	if team != "" {
+		if !a.dockerApp.HasTeam(team) {
+			return nil, ownership, errors.New("user=%s app=%s team %s not found", user.Username, appName, team)
+		}
+
+		if !docker.IsAdmin(user) && !a.dockerApp.IsMember(team, user.Username) {
+			return nil, ownership, errors.New("user=%s app=%s team %s is not yours", user.Username, appName, team)
+		}
+
+		ownership.Team = team
+	}
+
+	return oldContainers, ownership, nil
 }
 
 func (a *App) checkTasks(user *model.User, appName string) error {
@@ -67,6 +373,62 @@ func (a *App) checkTasks(user *model.User, appName string) error {
 	return nil
 }
 
+func getComposeStoreName(owner string, appName string) string {
+	return fmt.Sprintf("composes/%s/%s.yml", owner, appName)
+}
+
+func getFilesStoreName(owner string, appName string) string {
+	return fmt.Sprintf("files/%s/%s.json", owner, appName)
+}
+
+func getRequestVariablesStoreName(owner string, appName string) string {
+	return fmt.Sprintf("requests/%s/%s.json", owner, appName)
+}
+
+func (a *App) writeEncrypted(name string, kind string, appName string, content interface{}, empty bool) error {
+	if empty {
+		return a.storeApp.DeleteFile(name)
+	}
+
+	if !a.secretApp.Enabled() {
+		logger.Warn("app=%s %s are not kept for redeploy without secrets key", appName, kind)
+		return a.storeApp.DeleteFile(name)
+	}
+
+	return a.secretApp.Write(name, content)
+}
+
+func (a *App) writeDeploy(owner string, appName string, composeFile []byte, files map[string][]byte, variables map[string]string) error {
+	if err := a.storeApp.WriteFile(getComposeStoreName(owner, appName), composeFile); err != nil {
+		return err
+	}
+
+	if err := a.writeEncrypted(getFilesStoreName(owner, appName), "files", appName, files, len(files) == 0); err != nil {
+		return err
+	}
+
+	return a.writeEncrypted(getRequestVariablesStoreName(owner, appName), "request variables", appName, variables, len(variables) == 0)
+}
+
+func (a *App) readDeploy(owner string, appName string) ([]byte, map[string][]byte, map[string]string, error) {
+	composeFile, err := a.storeApp.ReadFile(getComposeStoreName(owner, appName))
+	if err != nil || len(composeFile) == 0 {
+		return nil, nil, nil, err
+	}
+
+	files := make(map[string][]byte)
+	if err := a.secretApp.Read(getFilesStoreName(owner, appName), &files); err != nil {
+		return nil, nil, nil, err
+	}
+
+	variables := make(map[string]string)
+	if err := a.secretApp.Read(getRequestVariablesStoreName(owner, appName), &variables); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return composeFile, files, variables, nil
+}
+
 func getServiceFullName(app string, service string) string {
 	return fmt.Sprintf("%s_%s%s", app, service, deploySuffix)
 }
@@ -84,3 +446,26 @@ func findServiceByContainerID(services map[string]*deployedService, containerID
 
 	return nil
 }
+
+func getRepoDigest(image string, repoDigests []string) (string, string) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", ""
+	}
+
+	for _, repoDigest := range repoDigests {
+		repoNamed, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil {
+			continue
+		}
+
+		canonical, ok := repoNamed.(reference.Canonical)
+		if !ok || canonical.Name() != named.Name() {
+			continue
+		}
+
+		return reference.FamiliarString(canonical), canonical.Digest().String()
+	}
+
+	return "", ""
+}
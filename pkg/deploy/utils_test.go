@@ -0,0 +1,129 @@
+package deploy
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/docker/docker/api/types"
+)
+
+func TestCheckRights(t *testing.T) {
+	app, clean := newTestApp(t, "[]")
+	defer clean()
+
+	if err := app.storeApp.Write("teams.json", map[string][]string{"web": {"alice", "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	dockerConfig := docker.Flags(fs, "docker")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dockerApp, err := docker.New(dockerConfig, app.storeApp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.dockerApp = dockerApp
+
+	if err := app.dockerApp.SetAppOwnership("wiki", docker.Ownership{Owner: "carol"}); err != nil {
+		t.Fatal(err)
+	}
+
+	app.dockerApp.Docker = &fakeDockerClient{
+		containers: []types.Container{
+			{ID: "blog_web", Labels: map[string]string{"app": "blog", "owner": "bob"}},
+			{ID: "blog_db", Labels: map[string]string{"app": "blog", "owner": "bob"}},
+			{ID: "legacy", Labels: map[string]string{"owner": "bob"}},
+			{ID: "shop_web", Labels: map[string]string{"app": "shop", "owner": "alice", "team": "web"}},
+		},
+	}
+
+	bob := model.NewUser("1", "bob", "", "")
+
+	var cases = []struct {
+		intention string
+		user      *model.User
+		app       string
+		team      string
+		want      []string
+		wantOwner docker.Ownership
+		wantErr   bool
+	}{
+		{
+			"should keep containers of deployed app and of deployer, not of its team's apps",
+			bob,
+			"blog",
+			"",
+			[]string{"blog_web", "blog_db", "legacy"},
+			docker.Ownership{Owner: "bob"},
+			false,
+		},
+		{
+			"should keep owner of team app deployed by member",
+			bob,
+			"shop",
+			"",
+			[]string{"blog_web", "blog_db", "legacy", "shop_web"},
+			docker.Ownership{Owner: "alice", Team: "web"},
+			false,
+		},
+		{
+			"should set team of member",
+			bob,
+			"blog",
+			"web",
+			[]string{"blog_web", "blog_db", "legacy"},
+			docker.Ownership{Owner: "bob", Team: "web"},
+			false,
+		},
+		{
+			"should refuse team of non member",
+			model.NewUser("2", "dave", "", ""),
+			"api",
+			"web",
+			nil,
+			docker.Ownership{Owner: "dave"},
+			true,
+		},
+		{
+			"should refuse app of another user",
+			bob,
+			"wiki",
+			"",
+			nil,
+			docker.Ownership{Owner: "carol"},
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		containers, ownership, err := app.checkRights(context.Background(), testCase.user, testCase.app, testCase.team)
+
+		var result []string
+		for _, container := range containers {
+			result = append(result, container.ID)
+		}
+
+		failed := false
+
+		if testCase.wantErr && err == nil {
+			failed = true
+		} else if !testCase.wantErr && err != nil {
+			failed = true
+		} else if !reflect.DeepEqual(result, testCase.want) {
+			failed = true
+		} else if ownership != testCase.wantOwner {
+			failed = true
+		}
+
+		if failed {
+			t.Errorf("%s\ncheckRights(%s, %#v, %#v) = (%v, %+v, %v), want (%v, %+v, error %t)", testCase.intention, testCase.user.Username, testCase.app, testCase.team, result, ownership, err, testCase.want, testCase.wantOwner, testCase.wantErr)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/httpjson"
+	"github.com/ViBiOh/httputils/pkg/request"
+)
+
+const variableParamPrefix = "var."
+
+var (
+	variablesRequest  = regexp.MustCompile(`^/([^/]+)/variables/?$`)
+	validVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+func getVariablesStoreName(owner string, appName string) string {
+	return fmt.Sprintf("variables/%s/%s.json", owner, appName)
+}
+
+func getRequestVariables(params url.Values) map[string]string {
+	variables := make(map[string]string)
+
+	for key, values := range params {
+		if strings.HasPrefix(key, variableParamPrefix) {
+			variables[strings.TrimPrefix(key, variableParamPrefix)] = values[0]
+		}
+	}
+
+	return variables
+}
+
+func (a *App) getVariables(user *model.User, appName string, params url.Values) (map[string]string, error) {
+	variables := make(map[string]string)
+	if err := a.storeApp.Read(getVariablesStoreName(a.dockerApp.GetAppOwner(user, appName), appName), &variables); err != nil {
+		return nil, err
+	}
+
+	for key, value := range getRequestVariables(params) {
+		variables[key] = value
+	}
+
+	return variables, nil
+}
+
+func parseVariables(r *http.Request) (map[string]string, error) {
+	payload, err := request.ReadBodyRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make(map[string]string)
+	if err := json.Unmarshal(payload, &variables); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for name := range variables {
+		if !validVariableName.MatchString(name) {
+			return nil, errors.New("invalid variable name `%s`", name)
+		}
+	}
+
+	return variables, nil
+}
+
+func (a *App) variablesHandler(w http.ResponseWriter, r *http.Request, user *model.User, appName string) {
+	if !a.isValidAppName(w, user, appName) {
+		return
+	}
+
+	if !a.dockerApp.Can(user, docker.DeployAction, appName) {
+		httperror.Forbidden(w)
+		return
+	}
+
+	storeName := getVariablesStoreName(a.dockerApp.GetAppOwner(user, appName), appName)
+
+	switch r.Method {
+	case http.MethodGet:
+		variables := make(map[string]string)
+		if err := a.storeApp.Read(storeName, &variables); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		if err := httpjson.ResponseJSON(w, http.StatusOK, variables, httpjson.IsPretty(r)); err != nil {
+			httperror.InternalServerError(w, err)
+		}
+
+	case http.MethodPut:
+		variables, err := parseVariables(r)
+		if err != nil {
+			httperror.BadRequest(w, err)
+			return
+		}
+
+		if err := a.storeApp.Write(storeName, variables); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if err := a.storeApp.DeleteFile(storeName); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
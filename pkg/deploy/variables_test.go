@@ -0,0 +1,155 @@
+package deploy
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/dashboard/pkg/registry"
+	"github.com/ViBiOh/dashboard/pkg/secret"
+	"github.com/ViBiOh/dashboard/pkg/store"
+)
+
+func newTestApp(t *testing.T, roles string) (*App, func()) {
+	directory, err := ioutil.TempDir("", "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rolesFile := path.Join(directory, "roles.json")
+	if err := ioutil.WriteFile(rolesFile, []byte(roles), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	storeConfig := store.Flags(fs, "store")
+	dockerConfig := docker.Flags(fs, "docker")
+	secretConfig := secret.Flags(fs, "secrets")
+	if err := fs.Parse([]string{"-storeDirectory", path.Join(directory, "store"), "-dockerRoles", rolesFile, "-secretsKey", "dashboard"}); err != nil {
+		t.Fatal(err)
+	}
+
+	storeApp, err := store.New(storeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dockerApp, err := docker.New(dockerConfig, storeApp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secretApp, err := secret.New(secretConfig, storeApp, dockerApp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registryApp, err := registry.New(secretApp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &App{dockerApp: dockerApp, storeApp: storeApp, secretApp: secretApp, registryApp: registryApp}, func() {
+		os.RemoveAll(directory)
+	}
+}
+
+func TestDataHandlersPermissions(t *testing.T) {
+	app, clean := newTestApp(t, `[{"profile": "viewer", "actions": ["get"]}, {"profile": "deployer", "actions": ["get", "deploy"]}]`)
+	defer clean()
+
+	viewer := model.NewUser("1", "alice", "", "viewer")
+	deployer := model.NewUser("2", "bob", "", "deployer")
+
+	variablesHandler := func(w http.ResponseWriter, r *http.Request, user *model.User) {
+		app.variablesHandler(w, r, user, "blog")
+	}
+	envsHandler := func(w http.ResponseWriter, r *http.Request, user *model.User) {
+		app.envsHandler(w, r, user, "blog")
+	}
+	envHandler := func(w http.ResponseWriter, r *http.Request, user *model.User) {
+		app.envHandler(w, r, user, "blog", "prod.env")
+	}
+
+	var cases = []struct {
+		intention string
+		handler   func(http.ResponseWriter, *http.Request, *model.User)
+		user      *model.User
+		method    string
+		body      string
+		want      int
+	}{
+		{
+			"should refuse variables values to viewer",
+			variablesHandler,
+			viewer,
+			http.MethodGet,
+			"",
+			http.StatusForbidden,
+		},
+		{
+			"should refuse variables change to viewer",
+			variablesHandler,
+			viewer,
+			http.MethodPut,
+			`{"DOMAIN": "vibioh.fr"}`,
+			http.StatusForbidden,
+		},
+		{
+			"should allow variables change to deployer",
+			variablesHandler,
+			deployer,
+			http.MethodPut,
+			`{"DOMAIN": "vibioh.fr"}`,
+			http.StatusNoContent,
+		},
+		{
+			"should list env sets for viewer",
+			envsHandler,
+			viewer,
+			http.MethodGet,
+			"",
+			http.StatusOK,
+		},
+		{
+			"should refuse env set content to viewer",
+			envHandler,
+			viewer,
+			http.MethodGet,
+			"",
+			http.StatusForbidden,
+		},
+		{
+			"should refuse env set change to viewer",
+			envHandler,
+			viewer,
+			http.MethodDelete,
+			"",
+			http.StatusForbidden,
+		},
+		{
+			"should allow env set change to deployer",
+			envHandler,
+			deployer,
+			http.MethodPut,
+			"DEBUG=false",
+			http.StatusNoContent,
+		},
+	}
+
+	for _, testCase := range cases {
+		writer := httptest.NewRecorder()
+		testCase.handler(writer, httptest.NewRequest(testCase.method, "/", strings.NewReader(testCase.body)), testCase.user)
+
+		if result := writer.Code; result != testCase.want {
+			t.Errorf("%s\n%s by %s = %d, want %d", testCase.intention, testCase.method, testCase.user.Username, result, testCase.want)
+		}
+	}
+}
@@ -4,28 +4,25 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/audit"
 	"github.com/ViBiOh/dashboard/pkg/commons"
 	"github.com/ViBiOh/httputils/pkg/errors"
 	"github.com/ViBiOh/httputils/pkg/httperror"
 	"github.com/ViBiOh/httputils/pkg/httpjson"
-	"github.com/ViBiOh/httputils/pkg/logger"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	opentracing "github.com/opentracing/opentracing-go"
 )
 
 const (
-	getAction     = "get"
-	startAction   = "start"
-	stopAction    = "stop"
-	restartAction = "restart"
-	deleteAction  = "delete"
+	redactedValue = "********"
 )
 
-// ListContainers list containers for user and app if provided
+// ListContainers list containers for user, including ones shared through a team, and app if provided
 func (a *App) ListContainers(ctx context.Context, user *model.User, appName string) ([]types.Container, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Docker list")
 	defer span.Finish()
@@ -33,12 +30,26 @@ func (a *App) ListContainers(ctx context.Context, user *model.User, appName stri
 	span.SetTag("app", appName)
 
 	options := types.ContainerListOptions{All: true}
-
 	options.Filters = filters.NewArgs()
-	LabelFilters(user, &options.Filters, appName)
+
+	shared := !IsAdmin(user) && (appName == "" || !isMultiApp(user))
+	if !shared {
+		LabelFilters(user, &options.Filters, appName)
+	}
 
 	output, err := a.Docker.ContainerList(ctx, options)
-	return output, errors.WithStack(err)
+	if err != nil || !shared {
+		return output, errors.WithStack(err)
+	}
+
+	owned := make([]types.Container, 0, len(output))
+	for _, container := range output {
+		if a.IsOwner(user, container.Labels) {
+			owned = append(owned, container)
+		}
+	}
+
+	return owned, nil
 }
 
 // InspectContainer get detailed information of a container
@@ -51,8 +62,35 @@ func (a *App) InspectContainer(ctx context.Context, containerID string) (*types.
 	return &container, errors.WithStack(err)
 }
 
+func redactSecrets(container *types.ContainerJSON) *types.ContainerJSON {
+	if container == nil || container.Config == nil || container.Config.Labels[commons.SecretsLabel] == "" {
+		return container
+	}
+
+	secrets := make(map[string]bool)
+	for _, name := range strings.Split(container.Config.Labels[commons.SecretsLabel], ",") {
+		secrets[name] = true
+	}
+
+	config := *container.Config
+	config.Env = make([]string, len(container.Config.Env))
+
+	for index, env := range container.Config.Env {
+		if parts := strings.SplitN(env, "=", 2); secrets[parts[0]] {
+			env = fmt.Sprintf("%s=%s", parts[0], redactedValue)
+		}
+
+		config.Env[index] = env
+	}
+
+	redacted := *container
+	redacted.Config = &config
+
+	return &redacted
+}
+
 func getContainer(_ context.Context, containerID string, container *types.ContainerJSON) (interface{}, error) {
-	return container, nil
+	return redactSecrets(container), nil
 }
 
 // StartContainer start a container
@@ -98,13 +136,8 @@ func (a *App) GracefulRestartContainer(ctx context.Context, containerID string,
 	return nil, errors.WithStack(a.Docker.ContainerRestart(timeoutCtx, containerID, &gracefulTimeout))
 }
 
-// RmContainerAndImages clean env
-func (a *App) RmContainerAndImages(ctx context.Context, containerID string, container *types.ContainerJSON) (interface{}, error) {
-	return a.RmContainer(ctx, containerID, container, true)
-}
-
-// RmContainer remove a container
-func (a *App) RmContainer(ctx context.Context, containerID string, container *types.ContainerJSON, failOnImageFail bool) (interface{}, error) {
+// RmContainer remove a container, its image being kept for retention and its app removed with last container
+func (a *App) RmContainer(ctx context.Context, containerID string, container *types.ContainerJSON) (interface{}, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Docker rm")
 	defer span.Finish()
 	span.SetTag("id", containerID)
@@ -121,12 +154,8 @@ func (a *App) RmContainer(ctx context.Context, containerID string, container *ty
 		return nil, errors.WithStack(err)
 	}
 
-	if err = a.RmImage(ctx, container.Image); err != nil {
-		if failOnImageFail {
-			return nil, err
-		}
-		logger.Error("%+v", err)
-	}
+	a.trackImage(container)
+	a.removeApp(ctx, container)
 
 	return nil, nil
 }
@@ -137,7 +166,7 @@ func (a *App) RmImage(ctx context.Context, imageID string) error {
 	defer span.Finish()
 	span.SetTag("id", imageID)
 
-	_, err := a.Docker.ImageRemove(ctx, imageID, types.ImageRemoveOptions{})
+	_, err := a.Docker.ImageRemove(ctx, imageID, types.ImageRemoveOptions{PruneChildren: true})
 	return errors.WithStack(err)
 }
 
@@ -147,16 +176,16 @@ func invalidAction(_ context.Context, action string, _ *types.ContainerJSON) (in
 
 func (a *App) doAction(action string) func(context.Context, string, *types.ContainerJSON) (interface{}, error) {
 	switch action {
-	case getAction:
+	case GetAction:
 		return getContainer
-	case startAction:
+	case StartAction:
 		return a.StartContainer
-	case stopAction:
+	case StopAction:
 		return a.StopContainer
-	case restartAction:
+	case RestartAction:
 		return a.RestartContainer
-	case deleteAction:
-		return a.RmContainerAndImages
+	case DeleteAction:
+		return a.RmContainer
 	default:
 		return invalidAction
 	}
@@ -166,18 +195,26 @@ func (a *App) basicActionHandler(w http.ResponseWriter, r *http.Request, user *m
 	ctx, cancel := commons.GetCtx(r.Context())
 	defer cancel()
 
-	allowed, container, err := a.isAllowed(ctx, user, containerID)
+	allowed, container, err := a.IsAllowed(ctx, user, containerID, action)
 	if err != nil {
+		a.recordAction(user, action, containerID, nil, err)
 		httperror.InternalServerError(w, err)
 		return
 	}
 
 	if !allowed {
+		entry := audit.NewEntry(user, action, nil)
+		entry.Container = containerID
+		entry.Result = audit.Denied
+		a.auditApp.Record(entry)
+
 		httperror.Forbidden(w)
 		return
 	}
 
 	result, err := a.doAction(action)(ctx, containerID, container)
+	a.recordAction(user, action, containerID, container, err)
+
 	if err != nil {
 		httperror.InternalServerError(w, err)
 		return
@@ -189,6 +226,20 @@ func (a *App) basicActionHandler(w http.ResponseWriter, r *http.Request, user *m
 	}
 }
 
+func (a *App) recordAction(user *model.User, action, containerID string, container *types.ContainerJSON, err error) {
+	entry := audit.NewEntry(user, action, err)
+	entry.Container = containerID
+
+	if container != nil && container.Config != nil {
+		entry.App = container.Config.Labels[commons.AppLabel]
+		if container.Name != "" {
+			entry.Params = map[string]string{"name": strings.TrimPrefix(container.Name, "/")}
+		}
+	}
+
+	a.auditApp.Record(entry)
+}
+
 // ListContainersHandler handler list of containers
 func (a *App) ListContainersHandler(w http.ResponseWriter, r *http.Request, user *model.User) {
 	ctx, cancel := commons.GetCtx(r.Context())
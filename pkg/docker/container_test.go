@@ -5,7 +5,9 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/ViBiOh/dashboard/pkg/commons"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 )
 
 func TestGetContainer(t *testing.T) {
@@ -21,6 +23,22 @@ func TestGetContainer(t *testing.T) {
 			&types.ContainerJSON{},
 			nil,
 		},
+		{
+			"test",
+			&types.ContainerJSON{
+				Config: &container.Config{
+					Env:    []string{"DEBUG=true", "DB_PASSWORD=s3cr3t"},
+					Labels: map[string]string{commons.SecretsLabel: "DB_PASSWORD"},
+				},
+			},
+			&types.ContainerJSON{
+				Config: &container.Config{
+					Env:    []string{"DEBUG=true", "DB_PASSWORD=********"},
+					Labels: map[string]string{commons.SecretsLabel: "DB_PASSWORD"},
+				},
+			},
+			nil,
+		},
 	}
 
 	var failed bool
@@ -6,9 +6,14 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ViBiOh/auth/pkg/auth"
+	"github.com/ViBiOh/dashboard/pkg/audit"
 	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/dashboard/pkg/store"
 	"github.com/ViBiOh/httputils/pkg/errors"
 	"github.com/ViBiOh/httputils/pkg/httperror"
 	"github.com/ViBiOh/httputils/pkg/tools"
@@ -23,33 +28,89 @@ var (
 
 // Config of package
 type Config struct {
-	host    *string
-	version *string
+	host           *string
+	version        *string
+	imageRetention *uint
+	imageCollect   *string
+	roles          *string
 }
 
 // App of package
 type App struct {
-	Docker     client.APIClient
-	wsUpgrader websocket.Upgrader
+	Docker               client.APIClient
+	wsUpgrader           websocket.Upgrader
+	storeApp             *store.App
+	auditApp             *audit.App
+	imagesHistory        map[string][]imageRecord
+	imagesMutex          sync.Mutex
+	imageRetention       uint
+	imageCollectInterval time.Duration
+	deploys              uint
+	deploysMutex         sync.Mutex
+	roles                []role
+	teams                map[string][]string
+	owners               map[string]Ownership
+	teamsMutex           sync.RWMutex
+	appRemovedHandlers   []func(string)
 }
 
 // Flags adds flags for configuring package
 func Flags(fs *flag.FlagSet, prefix string) Config {
 	return Config{
-		host:    fs.String(tools.ToCamel(fmt.Sprintf("%sHost", prefix)), "unix:///var/run/docker.sock", "[docker] Host"),
-		version: fs.String(tools.ToCamel(fmt.Sprintf("%sVersion", prefix)), "", "[docker] API Version"),
+		host:           fs.String(tools.ToCamel(fmt.Sprintf("%sHost", prefix)), "unix:///var/run/docker.sock", "[docker] Host"),
+		version:        fs.String(tools.ToCamel(fmt.Sprintf("%sVersion", prefix)), "", "[docker] API Version"),
+		imageRetention: fs.Uint(tools.ToCamel(fmt.Sprintf("%sImageRetention", prefix)), 2, "[docker] Number of unused images kept per app's service"),
+		imageCollect:   fs.String(tools.ToCamel(fmt.Sprintf("%sImageCollect", prefix)), "1h", "[docker] Interval between unused images collection, 0 for disabling"),
+		roles:          fs.String(tools.ToCamel(fmt.Sprintf("%sRoles", prefix)), "", "[docker] Path to roles JSON file"),
 	}
 }
 
 // New creates new App from Config
-func New(config Config) (*App, error) {
+func New(config Config, storeApp *store.App, auditApp *audit.App) (*App, error) {
 	client, err := client.NewClient(*config.host, *config.version, nil, nil)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
+	imageCollectInterval, err := time.ParseDuration(strings.TrimSpace(*config.imageCollect))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if imageCollectInterval < 0 {
+		return nil, errors.New("invalid image collect interval %s", imageCollectInterval)
+	}
+
+	roles, err := loadRoles(*config.roles)
+	if err != nil {
+		return nil, err
+	}
+
+	imagesHistory := make(map[string][]imageRecord)
+	if err := storeApp.Read(imagesStoreName, &imagesHistory); err != nil {
+		return nil, err
+	}
+
+	teams := make(map[string][]string)
+	if err := storeApp.Read(teamsStoreName, &teams); err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]Ownership)
+	if err := storeApp.Read(ownersStoreName, &owners); err != nil {
+		return nil, err
+	}
+
 	return &App{
-		Docker: client,
+		Docker:               client,
+		storeApp:             storeApp,
+		auditApp:             auditApp,
+		imagesHistory:        imagesHistory,
+		imageRetention:       *config.imageRetention,
+		imageCollectInterval: imageCollectInterval,
+		roles:                roles,
+		teams:                teams,
+		owners:               owners,
 	}, nil
 }
 
@@ -79,9 +140,9 @@ func (a *App) Handler() http.Handler {
 			containerID := containerRequest.FindStringSubmatch(r.URL.Path)[1]
 
 			if r.Method == http.MethodGet {
-				a.basicActionHandler(w, r, user, containerID, getAction)
+				a.basicActionHandler(w, r, user, containerID, GetAction)
 			} else if r.Method == http.MethodDelete {
-				a.basicActionHandler(w, r, user, containerID, deleteAction)
+				a.basicActionHandler(w, r, user, containerID, DeleteAction)
 			} else {
 				w.WriteHeader(http.StatusMethodNotAllowed)
 			}
@@ -0,0 +1,291 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ViBiOh/auth/pkg/auth"
+	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/httpjson"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/docker/docker/api/types"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+const imagesStoreName = "images.json"
+
+var errDeploysRunning = errors.New("deploys are running")
+
+type imageRecord struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Removed time.Time `json:"removed"`
+}
+
+// ImageReport describes an image collected, or to be collected
+type ImageReport struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags"`
+	Size int64    `json:"size"`
+}
+
+func getImageHistoryKey(container *types.ContainerJSON) string {
+	if container.Config != nil {
+		if appName, ok := container.Config.Labels[commons.AppLabel]; ok {
+			return fmt.Sprintf("%s/%s", appName, container.Config.Labels[commons.ServiceLabel])
+		}
+	}
+
+	return container.Name
+}
+
+func appendImageRecord(records []imageRecord, record imageRecord) []imageRecord {
+	output := make([]imageRecord, 0, len(records)+1)
+
+	for _, existing := range records {
+		if existing.ID != record.ID {
+			output = append(output, existing)
+		}
+	}
+
+	return append(output, record)
+}
+
+func getCollectableImages(history map[string][]imageRecord, used map[string]bool, retention int) map[string]bool {
+	retained := make(map[string]bool)
+	tracked := make(map[string]bool)
+
+	for _, records := range history {
+		for index, record := range records {
+			tracked[record.ID] = true
+
+			if index >= len(records)-retention {
+				retained[record.ID] = true
+			}
+		}
+	}
+
+	collectable := make(map[string]bool)
+	for id := range tracked {
+		if !retained[id] && !used[id] {
+			collectable[id] = true
+		}
+	}
+
+	return collectable
+}
+
+func (a *App) saveImagesHistory() {
+	if !a.storeApp.Enabled() {
+		return
+	}
+
+	if err := a.storeApp.Write(imagesStoreName, a.imagesHistory); err != nil {
+		logger.Error("%+v", err)
+	}
+}
+
+func (a *App) trackImage(container *types.ContainerJSON) {
+	if container == nil || container.Image == "" {
+		return
+	}
+
+	name := ""
+	if container.Config != nil {
+		name = container.Config.Image
+	}
+
+	key := getImageHistoryKey(container)
+
+	a.imagesMutex.Lock()
+	defer a.imagesMutex.Unlock()
+
+	a.imagesHistory[key] = appendImageRecord(a.imagesHistory[key], imageRecord{
+		ID:      container.Image,
+		Name:    name,
+		Removed: time.Now(),
+	})
+
+	a.saveImagesHistory()
+}
+
+func (a *App) getUsedImages(ctx context.Context) (map[string]bool, error) {
+	containers, err := a.Docker.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	used := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		used[container.ImageID] = true
+	}
+
+	return used, nil
+}
+
+func (a *App) pruneImagesHistory(existing map[string]bool) {
+	for key, records := range a.imagesHistory {
+		pruned := make([]imageRecord, 0, len(records))
+		for _, record := range records {
+			if existing[record.ID] {
+				pruned = append(pruned, record)
+			}
+		}
+
+		if len(pruned) == 0 {
+			delete(a.imagesHistory, key)
+		} else {
+			a.imagesHistory[key] = pruned
+		}
+	}
+
+	a.saveImagesHistory()
+}
+
+// DeployStarted marks a deploy as running, images not being collected until it ends
+func (a *App) DeployStarted() {
+	a.deploysMutex.Lock()
+	defer a.deploysMutex.Unlock()
+
+	a.deploys++
+}
+
+// DeployEnded marks a deploy as ended
+func (a *App) DeployEnded() {
+	a.deploysMutex.Lock()
+	defer a.deploysMutex.Unlock()
+
+	if a.deploys > 0 {
+		a.deploys--
+	}
+}
+
+// CollectImages removes images neither used by a container nor retained, only reports them if dryRun
+func (a *App) CollectImages(ctx context.Context, dryRun bool) ([]ImageReport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Docker images collect")
+	defer span.Finish()
+	span.SetTag("dryRun", dryRun)
+
+	if !dryRun {
+		a.deploysMutex.Lock()
+		defer a.deploysMutex.Unlock()
+
+		if a.deploys > 0 {
+			return nil, errDeploysRunning
+		}
+	}
+
+	used, err := a.getUsedImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := a.Docker.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	a.imagesMutex.Lock()
+	defer a.imagesMutex.Unlock()
+
+	collectable := getCollectableImages(a.imagesHistory, used, int(a.imageRetention))
+	reports := make([]ImageReport, 0)
+	existing := make(map[string]bool, len(images))
+
+	for _, image := range images {
+		existing[image.ID] = true
+
+		if !collectable[image.ID] {
+			continue
+		}
+
+		if !dryRun {
+			if err := a.RmImage(ctx, image.ID); err != nil {
+				logger.Error("%+v", err)
+				continue
+			}
+
+			delete(existing, image.ID)
+		}
+
+		reports = append(reports, ImageReport{
+			ID:   image.ID,
+			Tags: image.RepoTags,
+			Size: image.Size,
+		})
+	}
+
+	if !dryRun {
+		a.pruneImagesHistory(existing)
+	}
+
+	return reports, nil
+}
+
+// ImageCollector collects unused images periodically, blocking
+func (a *App) ImageCollector() {
+	if a.imageCollectInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(a.imageCollectInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), a.imageCollectInterval)
+
+		reports, err := a.CollectImages(ctx, false)
+		if err == errDeploysRunning {
+			logger.Info("unused images collection skipped: %v", err)
+		} else if err != nil {
+			logger.Error("%+v", err)
+		} else if len(reports) > 0 {
+			logger.Info("%d unused images collected", len(reports))
+		}
+
+		cancel()
+	}
+}
+
+// ImagesHandler for images request. Should be use with net/http
+func (a *App) ImagesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			httperror.BadRequest(w, errors.New("user not provided"))
+			return
+		}
+
+		if !IsAdmin(user) {
+			httperror.Forbidden(w)
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.URL.Path != "/" && r.URL.Path != "" {
+			httperror.NotFound(w)
+			return
+		}
+
+		ctx, cancel := commons.GetCtx(r.Context())
+		defer cancel()
+
+		reports, err := a.CollectImages(ctx, true)
+		if err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		if err := httpjson.ResponseArrayJSON(w, http.StatusOK, reports, httpjson.IsPretty(r)); err != nil {
+			httperror.InternalServerError(w, err)
+		}
+	})
+}
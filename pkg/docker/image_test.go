@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestGetImageHistoryKey(t *testing.T) {
+	var cases = []struct {
+		container *types.ContainerJSON
+		want      string
+	}{
+		{
+			&types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{Name: "/portainer"}, Config: &container.Config{}},
+			"/portainer",
+		},
+		{
+			&types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{Name: "/dashboard_api"}, Config: &container.Config{Labels: map[string]string{"app": "dashboard", "service": "api"}}},
+			"dashboard/api",
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := getImageHistoryKey(testCase.container); result != testCase.want {
+			t.Errorf("getImageHistoryKey(%v) = %v, want %v", testCase.container, result, testCase.want)
+		}
+	}
+}
+
+func TestAppendImageRecord(t *testing.T) {
+	var cases = []struct {
+		records []imageRecord
+		record  imageRecord
+		want    []imageRecord
+	}{
+		{
+			nil,
+			imageRecord{ID: "sha256:1"},
+			[]imageRecord{{ID: "sha256:1"}},
+		},
+		{
+			[]imageRecord{{ID: "sha256:1"}, {ID: "sha256:2"}},
+			imageRecord{ID: "sha256:1"},
+			[]imageRecord{{ID: "sha256:2"}, {ID: "sha256:1"}},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := appendImageRecord(testCase.records, testCase.record); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("appendImageRecord(%v, %v) = %v, want %v", testCase.records, testCase.record, result, testCase.want)
+		}
+	}
+}
+
+func TestGetCollectableImages(t *testing.T) {
+	history := map[string][]imageRecord{
+		"dashboard/api": {{ID: "sha256:1"}, {ID: "sha256:2"}, {ID: "sha256:3"}},
+		"dashboard/ui":  {{ID: "sha256:4"}, {ID: "sha256:2"}},
+	}
+
+	var cases = []struct {
+		intention string
+		used      map[string]bool
+		retention int
+		want      map[string]bool
+	}{
+		{
+			"should collect everything without retention",
+			nil,
+			0,
+			map[string]bool{"sha256:1": true, "sha256:2": true, "sha256:3": true, "sha256:4": true},
+		},
+		{
+			"should keep used images",
+			map[string]bool{"sha256:1": true, "sha256:4": true},
+			0,
+			map[string]bool{"sha256:2": true, "sha256:3": true},
+		},
+		{
+			"should keep last images of each service",
+			nil,
+			1,
+			map[string]bool{"sha256:1": true, "sha256:4": true},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := getCollectableImages(history, testCase.used, testCase.retention); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\ngetCollectableImages(%v, %v, %v) = %v, want %v", testCase.intention, history, testCase.used, testCase.retention, result, testCase.want)
+		}
+	}
+}
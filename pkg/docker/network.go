@@ -0,0 +1,108 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+const appNetworkSuffix = "_default"
+
+// GetAppNetworkName computes name of the private network of an app
+func GetAppNetworkName(appName string) string {
+	return fmt.Sprintf("%s%s", appName, appNetworkSuffix)
+}
+
+// CreateAppNetwork creates private network of an app if not already present
+func (a *App) CreateAppNetwork(ctx context.Context, user *model.User, appName string) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Docker network create")
+	defer span.Finish()
+	span.SetTag("app", appName)
+
+	networkName := GetAppNetworkName(appName)
+
+	existing, err := a.Docker.NetworkInspect(ctx, networkName, types.NetworkInspectOptions{})
+	if err == nil {
+		if !a.IsOwner(user, existing.Labels) || existing.Labels[commons.AppLabel] != appName {
+			return "", errors.New("network %s is not yours", networkName)
+		}
+
+		return networkName, nil
+	} else if !client.IsErrNotFound(err) {
+		return "", errors.WithStack(err)
+	}
+
+	_, err = a.Docker.NetworkCreate(ctx, networkName, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Labels: map[string]string{
+			commons.OwnerLabel: user.Username,
+			commons.AppLabel:   appName,
+		},
+	})
+
+	return networkName, errors.WithStack(err)
+}
+
+// OnAppRemoved registers handler called with app name when its last container is removed
+func (a *App) OnAppRemoved(handler func(string)) {
+	a.appRemovedHandlers = append(a.appRemovedHandlers, handler)
+}
+
+func (a *App) removeApp(ctx context.Context, container *types.ContainerJSON) {
+	if container == nil || container.Config == nil {
+		return
+	}
+
+	appName := container.Config.Labels[commons.AppLabel]
+	if appName == "" {
+		return
+	}
+
+	filtersArgs := filters.NewArgs()
+	filtersArgs.Add("label", fmt.Sprintf("%s=%s", commons.AppLabel, appName))
+
+	containers, err := a.Docker.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filtersArgs})
+	if err != nil {
+		logger.Error("app=%s %+v", appName, errors.WithStack(err))
+		return
+	}
+
+	if len(containers) > 0 {
+		return
+	}
+
+	for _, handler := range a.appRemovedHandlers {
+		handler(appName)
+	}
+
+	a.removeAppNetwork(ctx, appName)
+}
+
+func (a *App) removeAppNetwork(ctx context.Context, appName string) {
+	networkName := GetAppNetworkName(appName)
+
+	network, err := a.Docker.NetworkInspect(ctx, networkName, types.NetworkInspectOptions{})
+	if err != nil {
+		if !client.IsErrNotFound(err) {
+			logger.Error("app=%s %+v", appName, errors.WithStack(err))
+		}
+		return
+	}
+
+	if network.Labels[commons.AppLabel] != appName {
+		return
+	}
+
+	if err := a.Docker.NetworkRemove(ctx, networkName); err != nil {
+		logger.Error("app=%s %+v", appName, errors.WithStack(err))
+	}
+}
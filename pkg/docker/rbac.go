@@ -0,0 +1,119 @@
+package docker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/httputils/pkg/errors"
+)
+
+const (
+	// GetAction allows inspecting containers and receiving their events
+	GetAction = "get"
+	// StartAction allows starting containers
+	StartAction = "start"
+	// StopAction allows stopping containers
+	StopAction = "stop"
+	// RestartAction allows restarting containers
+	RestartAction = "restart"
+	// DeleteAction allows deleting containers
+	DeleteAction = "delete"
+	// DeployAction allows deploying apps
+	DeployAction = "deploy"
+	// LogsAction allows streaming logs of containers
+	LogsAction = "logs"
+	// StatsAction allows streaming stats of containers
+	StatsAction = "stats"
+	// ExecAction allows executing commands in containers
+	ExecAction = "exec"
+)
+
+var actions = []string{GetAction, StartAction, StopAction, RestartAction, DeleteAction, DeployAction, LogsAction, StatsAction, ExecAction}
+
+type role struct {
+	Profile string   `json:"profile"`
+	Actions []string `json:"actions"`
+	Apps    []string `json:"apps"`
+}
+
+func loadRoles(filename string) ([]role, error) {
+	if strings.TrimSpace(filename) == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var roles []role
+	if err := json.Unmarshal(content, &roles); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, item := range roles {
+		for _, action := range item.Actions {
+			if !contains(actions, action) {
+				return nil, errors.New("unknown action %s for profile `%s`", action, item.Profile)
+			}
+		}
+	}
+
+	return roles, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r role) grants(action, appName string) bool {
+	if !contains(r.Actions, action) {
+		return false
+	}
+
+	return appName == "" || len(r.Apps) == 0 || contains(r.Apps, appName)
+}
+
+// Can checks if user is allowed to do action on given app, an empty app matching any
+func (a *App) Can(user *model.User, action, appName string) bool {
+	if user == nil {
+		return false
+	}
+
+	if IsAdmin(user) || len(a.roles) == 0 {
+		return true
+	}
+
+	hasRole := false
+
+	for _, item := range a.roles {
+		if item.Profile == "" || !user.HasProfile(item.Profile) {
+			continue
+		}
+
+		hasRole = true
+		if item.grants(action, appName) {
+			return true
+		}
+	}
+
+	if hasRole {
+		return false
+	}
+
+	for _, item := range a.roles {
+		if item.Profile == "" && item.grants(action, appName) {
+			return true
+		}
+	}
+
+	return false
+}
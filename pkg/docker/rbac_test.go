@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+)
+
+func TestCan(t *testing.T) {
+	roles := []role{
+		{Actions: []string{GetAction, LogsAction}},
+		{Profile: "operator", Actions: []string{GetAction, StartAction, StopAction, RestartAction, LogsAction, StatsAction}},
+		{Profile: "deployer", Actions: []string{DeployAction}, Apps: []string{"api"}},
+	}
+
+	var cases = []struct {
+		intention string
+		roles     []role
+		user      *model.User
+		action    string
+		appName   string
+		want      bool
+	}{
+		{
+			"should deny without user",
+			nil,
+			nil,
+			GetAction,
+			"",
+			false,
+		},
+		{
+			"should allow everything without roles",
+			nil,
+			model.NewUser("0", "test", "", ""),
+			DeleteAction,
+			"api",
+			true,
+		},
+		{
+			"should allow everything for admin",
+			roles,
+			model.NewUser("0", "admin", "", "admin"),
+			ExecAction,
+			"api",
+			true,
+		},
+		{
+			"should use default role without matching profile",
+			roles,
+			model.NewUser("0", "test", "", ""),
+			LogsAction,
+			"api",
+			true,
+		},
+		{
+			"should deny action out of default role",
+			roles,
+			model.NewUser("0", "test", "", ""),
+			RestartAction,
+			"api",
+			false,
+		},
+		{
+			"should not fallback to default role with matching profile",
+			roles,
+			model.NewUser("0", "test", "", "deployer"),
+			GetAction,
+			"api",
+			false,
+		},
+		{
+			"should allow action of any matching profile",
+			roles,
+			model.NewUser("0", "test", "", "operator,deployer"),
+			DeployAction,
+			"api",
+			true,
+		},
+		{
+			"should deny app out of role",
+			roles,
+			model.NewUser("0", "test", "", "operator,deployer"),
+			DeployAction,
+			"front",
+			false,
+		},
+	}
+
+	for _, testCase := range cases {
+		app := App{roles: testCase.roles}
+
+		if result := app.Can(testCase.user, testCase.action, testCase.appName); result != testCase.want {
+			t.Errorf("%s\nCan(%+v, %s, %s) = %v, want %v", testCase.intention, testCase.user, testCase.action, testCase.appName, result, testCase.want)
+		}
+	}
+}
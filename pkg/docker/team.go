@@ -0,0 +1,282 @@
+package docker
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ViBiOh/auth/pkg/auth"
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/audit"
+	"github.com/ViBiOh/dashboard/pkg/commons"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/httpjson"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/ViBiOh/httputils/pkg/request"
+)
+
+const (
+	teamsStoreName  = "teams.json"
+	ownersStoreName = "owners.json"
+)
+
+var (
+	teamRequest   = regexp.MustCompile(`^/([^/]+)/?$`)
+	validTeamName = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]*[a-z0-9])?$`)
+
+	teamActions = map[string]string{
+		http.MethodPut:    "set_team",
+		http.MethodDelete: "delete_team",
+	}
+)
+
+// Ownership of an app, by an user and optionally shared with a team
+type Ownership struct {
+	Owner string `json:"owner"`
+	Team  string `json:"team,omitempty"`
+}
+
+// IsMember checks if given username belongs to team
+func (a *App) IsMember(team, username string) bool {
+	a.teamsMutex.RLock()
+	defer a.teamsMutex.RUnlock()
+
+	for _, member := range a.teams[team] {
+		if member == username {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasTeam checks if given team exists
+func (a *App) HasTeam(team string) bool {
+	a.teamsMutex.RLock()
+	defer a.teamsMutex.RUnlock()
+
+	_, ok := a.teams[team]
+	return ok
+}
+
+// GetAppOwnership returns ownership of app, stored one having precedence over given labels
+func (a *App) GetAppOwnership(appName string, labels map[string]string) Ownership {
+	if appName != "" {
+		a.teamsMutex.RLock()
+		ownership, ok := a.owners[appName]
+		a.teamsMutex.RUnlock()
+
+		if ok {
+			return ownership
+		}
+	}
+
+	return Ownership{
+		Owner: labels[commons.OwnerLabel],
+		Team:  labels[commons.TeamLabel],
+	}
+}
+
+// IsOwner checks if user owns resource with given labels, directly or through its team
+func (a *App) IsOwner(user *model.User, labels map[string]string) bool {
+	return a.IsOwnedBy(user, a.GetAppOwnership(labels[commons.AppLabel], labels))
+}
+
+// IsOwnedBy checks if ownership belongs to user, directly or through its team
+func (a *App) IsOwnedBy(user *model.User, ownership Ownership) bool {
+	if user == nil {
+		return false
+	}
+
+	if IsAdmin(user) {
+		return true
+	}
+
+	if ownership.Owner != "" && ownership.Owner == user.Username {
+		return true
+	}
+
+	return ownership.Team != "" && a.IsMember(ownership.Team, user.Username)
+}
+
+// GetAppOwner returns username owning data of app, the app's owner for members of its team
+func (a *App) GetAppOwner(user *model.User, appName string) string {
+	ownership := a.GetAppOwnership(appName, nil)
+	if ownership.Owner != "" && a.IsOwnedBy(user, ownership) {
+		return ownership.Owner
+	}
+
+	return user.Username
+}
+
+// SetAppOwnership stores ownership of app
+func (a *App) SetAppOwnership(appName string, ownership Ownership) error {
+	a.teamsMutex.Lock()
+	defer a.teamsMutex.Unlock()
+
+	owners := make(map[string]Ownership, len(a.owners)+1)
+	for name, existing := range a.owners {
+		owners[name] = existing
+	}
+	owners[appName] = ownership
+
+	if err := a.storeApp.Write(ownersStoreName, owners); err != nil {
+		return err
+	}
+
+	a.owners = owners
+	return nil
+}
+
+func (a *App) listTeams(user *model.User) map[string][]string {
+	a.teamsMutex.RLock()
+	defer a.teamsMutex.RUnlock()
+
+	output := make(map[string][]string)
+	for team, members := range a.teams {
+		for _, member := range members {
+			if IsAdmin(user) || member == user.Username {
+				output[team] = members
+				break
+			}
+		}
+	}
+
+	return output
+}
+
+func (a *App) saveTeam(team string, members []string) error {
+	a.teamsMutex.Lock()
+	defer a.teamsMutex.Unlock()
+
+	teams := make(map[string][]string, len(a.teams)+1)
+	for name, existing := range a.teams {
+		if name != team {
+			teams[name] = existing
+		}
+	}
+
+	if members != nil {
+		teams[team] = members
+	}
+
+	if err := a.storeApp.Write(teamsStoreName, teams); err != nil {
+		return err
+	}
+
+	a.teams = teams
+	return nil
+}
+
+func parseMembers(r *http.Request) ([]string, error) {
+	payload, err := request.ReadBodyRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []string
+	if err := json.Unmarshal(payload, &members); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	output := make([]string, 0, len(members))
+	for _, member := range members {
+		if member = strings.TrimSpace(member); member != "" {
+			output = append(output, member)
+		}
+	}
+
+	if len(output) == 0 {
+		return nil, errors.New("at least one member is required")
+	}
+
+	sort.Strings(output)
+	return output, nil
+}
+
+func (a *App) teamHandler(w http.ResponseWriter, r *http.Request, user *model.User, team string) {
+	if !IsAdmin(user) {
+		httperror.Forbidden(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if !validTeamName.MatchString(team) {
+			httperror.BadRequest(w, errors.New("invalid team name %s", team))
+			return
+		}
+
+		members, err := parseMembers(r)
+		if err != nil {
+			httperror.BadRequest(w, err)
+			return
+		}
+
+		if err := a.saveTeam(team, members); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		logger.Info("user=%s team=%s members saved", user.Username, team)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if !a.HasTeam(team) {
+			httperror.NotFound(w)
+			return
+		}
+
+		if err := a.saveTeam(team, nil); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		logger.Info("user=%s team=%s deleted", user.Username, team)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// TeamsHandler for teams request. Should be use with net/http
+func (a *App) TeamsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			httperror.BadRequest(w, errors.New("user not provided"))
+			return
+		}
+
+		if r.URL.Path == "/" || r.URL.Path == "" {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			if err := httpjson.ResponseJSON(w, http.StatusOK, a.listTeams(user), httpjson.IsPretty(r)); err != nil {
+				httperror.InternalServerError(w, err)
+			}
+			return
+		}
+
+		if !teamRequest.MatchString(r.URL.Path) {
+			httperror.NotFound(w)
+			return
+		}
+
+		team := teamRequest.FindStringSubmatch(r.URL.Path)[1]
+
+		action, ok := teamActions[r.Method]
+		if !ok {
+			a.teamHandler(w, r, user, team)
+			return
+		}
+
+		a.auditApp.RecordRequest(w, audit.Entry{User: user.Username, Action: action, Params: map[string]string{"team": team}}, func(writer http.ResponseWriter) {
+			a.teamHandler(writer, r, user, team)
+		})
+	})
+}
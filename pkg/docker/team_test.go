@@ -0,0 +1,163 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+)
+
+func TestIsOwner(t *testing.T) {
+	app := App{
+		teams: map[string][]string{
+			"web": {"alice", "bob"},
+		},
+		owners: map[string]Ownership{
+			"blog": {Owner: "carol"},
+		},
+	}
+
+	var cases = []struct {
+		intention string
+		user      *model.User
+		labels    map[string]string
+		want      bool
+	}{
+		{
+			"should deny without user",
+			nil,
+			map[string]string{"owner": "alice"},
+			false,
+		},
+		{
+			"should allow admin",
+			model.NewUser("0", "admin", "", "admin"),
+			map[string]string{"owner": "alice"},
+			true,
+		},
+		{
+			"should allow owner",
+			model.NewUser("0", "alice", "", ""),
+			map[string]string{"owner": "alice", "app": "api"},
+			true,
+		},
+		{
+			"should allow member of team",
+			model.NewUser("0", "bob", "", ""),
+			map[string]string{"owner": "alice", "team": "web", "app": "api"},
+			true,
+		},
+		{
+			"should deny user out of team",
+			model.NewUser("0", "carol", "", ""),
+			map[string]string{"owner": "alice", "team": "web", "app": "api"},
+			false,
+		},
+		{
+			"should deny labelled owner of transferred app",
+			model.NewUser("0", "alice", "", ""),
+			map[string]string{"owner": "alice", "app": "blog"},
+			false,
+		},
+		{
+			"should allow new owner of transferred app",
+			model.NewUser("0", "carol", "", ""),
+			map[string]string{"owner": "alice", "app": "blog"},
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := app.IsOwner(testCase.user, testCase.labels); result != testCase.want {
+			t.Errorf("%s\nIsOwner(%+v, %+v) = %v, want %v", testCase.intention, testCase.user, testCase.labels, result, testCase.want)
+		}
+	}
+}
+
+func TestListTeams(t *testing.T) {
+	app := App{
+		teams: map[string][]string{
+			"web": {"alice", "bob"},
+			"ops": {"carol"},
+		},
+	}
+
+	var cases = []struct {
+		intention string
+		user      *model.User
+		want      map[string][]string
+	}{
+		{
+			"should list all teams for admin",
+			model.NewUser("0", "admin", "", "admin"),
+			map[string][]string{"web": {"alice", "bob"}, "ops": {"carol"}},
+		},
+		{
+			"should list teams of user",
+			model.NewUser("0", "bob", "", ""),
+			map[string][]string{"web": {"alice", "bob"}},
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := app.listTeams(testCase.user); !reflect.DeepEqual(result, testCase.want) {
+			t.Errorf("%s\nlistTeams(%+v) = %+v, want %+v", testCase.intention, testCase.user, result, testCase.want)
+		}
+	}
+}
+
+func TestGetAppOwner(t *testing.T) {
+	app := App{
+		teams: map[string][]string{
+			"web": {"alice", "bob"},
+		},
+		owners: map[string]Ownership{
+			"blog": {Owner: "alice", Team: "web"},
+			"wiki": {Owner: "carol"},
+		},
+	}
+
+	var cases = []struct {
+		intention string
+		user      *model.User
+		app       string
+		want      string
+	}{
+		{
+			"should return owner for owner",
+			model.NewUser("0", "alice", "", ""),
+			"blog",
+			"alice",
+		},
+		{
+			"should return owner for member of team",
+			model.NewUser("0", "bob", "", ""),
+			"blog",
+			"alice",
+		},
+		{
+			"should return owner for admin",
+			model.NewUser("0", "admin", "", "admin"),
+			"wiki",
+			"carol",
+		},
+		{
+			"should return user for app of another owner",
+			model.NewUser("0", "bob", "", ""),
+			"wiki",
+			"bob",
+		},
+		{
+			"should return user for unknown app",
+			model.NewUser("0", "bob", "", ""),
+			"api",
+			"bob",
+		},
+	}
+
+	for _, testCase := range cases {
+		if result := app.GetAppOwner(testCase.user, testCase.app); result != testCase.want {
+			t.Errorf("%s\nGetAppOwner(%+v, %#v) = %#v, want %#v", testCase.intention, testCase.user, testCase.app, result, testCase.want)
+		}
+	}
+}
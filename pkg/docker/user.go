@@ -30,7 +30,8 @@ func isMultiApp(user *model.User) bool {
 	return user.HasProfile(adminUser) || user.HasProfile(multiAppUser)
 }
 
-func (a *App) isAllowed(ctx context.Context, user *model.User, containerID string) (bool, *types.ContainerJSON, error) {
+// IsAllowed checks if user owns given container and is allowed to do action on it
+func (a *App) IsAllowed(ctx context.Context, user *model.User, containerID, action string) (bool, *types.ContainerJSON, error) {
 	if user == nil {
 		return false, nil, commons.ErrUserRequired
 	}
@@ -40,11 +41,12 @@ func (a *App) isAllowed(ctx context.Context, user *model.User, containerID strin
 		return false, nil, err
 	}
 
-	if !IsAdmin(user) {
-		owner, ok := container.Config.Labels[commons.OwnerLabel]
-		if !ok || owner != user.Username {
-			return false, nil, nil
-		}
+	if !a.IsOwner(user, container.Config.Labels) {
+		return false, nil, nil
+	}
+
+	if !a.Can(user, action, container.Config.Labels[commons.AppLabel]) {
+		return false, nil, nil
 	}
 
 	return true, container, nil
@@ -0,0 +1,328 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ViBiOh/auth/pkg/auth"
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/audit"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/dashboard/pkg/secret"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/httpjson"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/ViBiOh/httputils/pkg/request"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	storeName       = "registries.json"
+	dockerHubDomain = "docker.io"
+)
+
+var (
+	registryRequest = regexp.MustCompile(`^/([^/]+)/?$`)
+
+	registryActions = map[string]string{
+		http.MethodPost:   "set_registry",
+		http.MethodDelete: "delete_registry",
+	}
+)
+
+// Credential for authenticating against a registry
+type Credential struct {
+	Registry string `json:"registry"`
+	User     string `json:"user,omitempty"`
+	App      string `json:"app,omitempty"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+}
+
+// App of package
+type App struct {
+	secretApp   *secret.App
+	auditApp    *audit.App
+	credentials []Credential
+	mutex       sync.RWMutex
+}
+
+// New creates new App
+func New(secretApp *secret.App, auditApp *audit.App) (*App, error) {
+	credentials := make([]Credential, 0)
+	if err := secretApp.Read(storeName, &credentials); err != nil {
+		return nil, err
+	}
+
+	return &App{
+		secretApp:   secretApp,
+		auditApp:    auditApp,
+		credentials: credentials,
+	}, nil
+}
+
+func normalizeRegistry(registry string) string {
+	registry = strings.ToLower(strings.TrimSpace(registry))
+
+	switch registry {
+	case "index.docker.io", "registry-1.docker.io":
+		return dockerHubDomain
+	default:
+		return registry
+	}
+}
+
+func getImageRegistry(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return normalizeRegistry(reference.Domain(named)), nil
+}
+
+func findCredential(credentials []Credential, registry, username, appName string) *Credential {
+	var found *Credential
+	foundScore := -1
+
+	for index, credential := range credentials {
+		if credential.Registry != registry {
+			continue
+		}
+
+		score := 0
+
+		if credential.User != "" {
+			if credential.User != username {
+				continue
+			}
+			score += 2
+		}
+
+		if credential.App != "" {
+			if credential.App != appName {
+				continue
+			}
+			score++
+		}
+
+		if score > foundScore {
+			found = &credentials[index]
+			foundScore = score
+		}
+	}
+
+	return found
+}
+
+// Auth computes encoded RegistryAuth for pulling given image, empty if no credential found
+func (a *App) Auth(user *model.User, appName, image string) (string, error) {
+	registry, err := getImageRegistry(image)
+	if err != nil {
+		return "", err
+	}
+
+	a.mutex.RLock()
+	credential := findCredential(a.credentials, registry, user.Username, appName)
+	a.mutex.RUnlock()
+
+	if credential == nil {
+		return "", nil
+	}
+
+	payload, err := json.Marshal(types.AuthConfig{
+		Username:      credential.Username,
+		Password:      credential.Password,
+		ServerAddress: credential.Registry,
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return base64.URLEncoding.EncodeToString(payload), nil
+}
+
+func (a *App) list() []Credential {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	output := make([]Credential, 0, len(a.credentials))
+	for _, credential := range a.credentials {
+		credential.Password = ""
+		output = append(output, credential)
+	}
+
+	return output
+}
+
+func (a *App) save(credential Credential) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	credentials := make([]Credential, 0, len(a.credentials)+1)
+	for _, existing := range a.credentials {
+		if existing.Registry != credential.Registry || existing.User != credential.User || existing.App != credential.App {
+			credentials = append(credentials, existing)
+		}
+	}
+	credentials = append(credentials, credential)
+
+	if err := a.secretApp.Write(storeName, credentials); err != nil {
+		return err
+	}
+
+	a.credentials = credentials
+	return nil
+}
+
+func (a *App) delete(registry, user, appName string) (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	credentials := make([]Credential, 0, len(a.credentials))
+	for _, existing := range a.credentials {
+		if existing.Registry != registry || existing.User != user || existing.App != appName {
+			credentials = append(credentials, existing)
+		}
+	}
+
+	if len(credentials) == len(a.credentials) {
+		return false, nil
+	}
+
+	if err := a.secretApp.Write(storeName, credentials); err != nil {
+		return false, err
+	}
+
+	a.credentials = credentials
+	return true, nil
+}
+
+func parseCredential(r *http.Request) (Credential, error) {
+	var credential Credential
+
+	payload, err := request.ReadBodyRequest(r)
+	if err != nil {
+		return credential, err
+	}
+
+	if err := json.Unmarshal(payload, &credential); err != nil {
+		return credential, errors.WithStack(err)
+	}
+
+	credential.Registry = normalizeRegistry(credential.Registry)
+	credential.User = strings.TrimSpace(credential.User)
+	credential.App = strings.TrimSpace(credential.App)
+
+	if credential.Registry == "" || strings.TrimSpace(credential.Username) == "" || credential.Password == "" {
+		return credential, errors.New("registry, username and password are required")
+	}
+
+	return credential, nil
+}
+
+func getAuditParams(registry, user, app string) map[string]string {
+	params := map[string]string{"registry": registry}
+
+	if user != "" {
+		params["user"] = user
+	}
+	if app != "" {
+		params["app"] = app
+	}
+
+	return params
+}
+
+func (a *App) saveHandler(w http.ResponseWriter, user *model.User, credential Credential, err error) {
+	if err != nil {
+		httperror.BadRequest(w, err)
+		return
+	}
+
+	if err := a.save(credential); err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	logger.Info("user=%s registry=%s credential saved", user.Username, credential.Registry)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) deleteHandler(w http.ResponseWriter, user *model.User, registry, credentialUser, credentialApp string) {
+	deleted, err := a.delete(registry, credentialUser, credentialApp)
+	if err != nil {
+		httperror.InternalServerError(w, err)
+		return
+	}
+
+	if !deleted {
+		httperror.NotFound(w)
+		return
+	}
+
+	logger.Info("user=%s registry=%s credential deleted", user.Username, registry)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for request. Should be use with net/http
+func (a *App) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			httperror.BadRequest(w, errors.New("user not provided"))
+			return
+		}
+
+		if !docker.IsAdmin(user) {
+			if action, ok := registryActions[r.Method]; ok {
+				a.auditApp.RecordRequest(w, audit.Entry{User: user.Username, Action: action}, httperror.Forbidden)
+				return
+			}
+
+			httperror.Forbidden(w)
+			return
+		}
+
+		if r.URL.Path == "/" || r.URL.Path == "" {
+			switch r.Method {
+			case http.MethodGet:
+				if err := httpjson.ResponseArrayJSON(w, http.StatusOK, a.list(), httpjson.IsPretty(r)); err != nil {
+					httperror.InternalServerError(w, err)
+				}
+			case http.MethodPost:
+				credential, err := parseCredential(r)
+
+				entry := audit.Entry{User: user.Username, Action: registryActions[r.Method], App: credential.App, Params: getAuditParams(credential.Registry, credential.User, credential.App)}
+				a.auditApp.RecordRequest(w, entry, func(writer http.ResponseWriter) {
+					a.saveHandler(writer, user, credential, err)
+				})
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		} else if registryRequest.MatchString(r.URL.Path) {
+			if r.Method != http.MethodDelete {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			registry := normalizeRegistry(registryRequest.FindStringSubmatch(r.URL.Path)[1])
+			query := r.URL.Query()
+			credentialUser := strings.TrimSpace(query.Get("user"))
+			credentialApp := strings.TrimSpace(query.Get("app"))
+
+			entry := audit.Entry{User: user.Username, Action: registryActions[r.Method], App: credentialApp, Params: getAuditParams(registry, credentialUser, credentialApp)}
+			a.auditApp.RecordRequest(w, entry, func(writer http.ResponseWriter) {
+				a.deleteHandler(writer, user, registry, credentialUser, credentialApp)
+			})
+		} else {
+			httperror.NotFound(w)
+		}
+	})
+}
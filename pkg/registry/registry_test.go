@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"testing"
+)
+
+func TestGetImageRegistry(t *testing.T) {
+	var cases = []struct {
+		image string
+		want  string
+	}{
+		{
+			"vibioh/dashboard",
+			"docker.io",
+		},
+		{
+			"nginx:alpine",
+			"docker.io",
+		},
+		{
+			"registry.vibioh.fr:5000/team/api:1.0.0",
+			"registry.vibioh.fr:5000",
+		},
+	}
+
+	for _, testCase := range cases {
+		if result, err := getImageRegistry(testCase.image); result != testCase.want || err != nil {
+			t.Errorf("getImageRegistry(%v) = (%v, %v), want %v", testCase.image, result, err, testCase.want)
+		}
+	}
+}
+
+func TestFindCredential(t *testing.T) {
+	credentials := []Credential{
+		{Registry: "registry.vibioh.fr", Username: "global"},
+		{Registry: "registry.vibioh.fr", App: "dashboard", Username: "app"},
+		{Registry: "registry.vibioh.fr", User: "vibioh", Username: "user"},
+		{Registry: "registry.vibioh.fr", User: "vibioh", App: "dashboard", Username: "userApp"},
+	}
+
+	var cases = []struct {
+		intention string
+		registry  string
+		user      string
+		app       string
+		want      string
+	}{
+		{
+			"should find nothing for unknown registry",
+			"docker.io",
+			"vibioh",
+			"dashboard",
+			"",
+		},
+		{
+			"should fallback to global credential",
+			"registry.vibioh.fr",
+			"guest",
+			"website",
+			"global",
+		},
+		{
+			"should use app credential",
+			"registry.vibioh.fr",
+			"guest",
+			"dashboard",
+			"app",
+		},
+		{
+			"should use user credential",
+			"registry.vibioh.fr",
+			"vibioh",
+			"website",
+			"user",
+		},
+		{
+			"should use most specific credential",
+			"registry.vibioh.fr",
+			"vibioh",
+			"dashboard",
+			"userApp",
+		},
+	}
+
+	for _, testCase := range cases {
+		result := ""
+		if credential := findCredential(credentials, testCase.registry, testCase.user, testCase.app); credential != nil {
+			result = credential.Username
+		}
+
+		if result != testCase.want {
+			t.Errorf("%s\nfindCredential(%v, %v, %v) = %v, want %v", testCase.intention, testCase.registry, testCase.user, testCase.app, result, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ViBiOh/auth/pkg/auth"
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/audit"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/dashboard/pkg/store"
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/httperror"
+	"github.com/ViBiOh/httputils/pkg/httpjson"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/ViBiOh/httputils/pkg/request"
+	"github.com/ViBiOh/httputils/pkg/tools"
+)
+
+var (
+	appRequest    = regexp.MustCompile(`^/([^/]+)/?$`)
+	secretRequest = regexp.MustCompile(`^/([^/]+)/([^/]+)/?$`)
+	validName     = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+	secretActions = map[string]string{
+		http.MethodPut:    "set_secret",
+		http.MethodDelete: "delete_secret",
+	}
+
+	// ErrNotConfigured occurs when secrets are used without key
+	ErrNotConfigured = errors.New("no secrets key configured")
+)
+
+// Config of package
+type Config struct {
+	key *string
+}
+
+// App of package
+type App struct {
+	storeApp  *store.App
+	dockerApp *docker.App
+	auditApp  *audit.App
+	aead      cipher.AEAD
+	mutex     sync.Mutex
+}
+
+// Flags adds flags for configuring package
+func Flags(fs *flag.FlagSet, prefix string) Config {
+	return Config{
+		key: fs.String(tools.ToCamel(fmt.Sprintf("%sKey", prefix)), "", "[secret] Key for encrypting secrets at rest"),
+	}
+}
+
+// New creates new App from Config
+func New(config Config, storeApp *store.App, dockerApp *docker.App, auditApp *audit.App) (*App, error) {
+	key := strings.TrimSpace(*config.key)
+	if key == "" {
+		logger.Warn("no secrets key provided, secrets are disabled")
+		return &App{
+			storeApp:  storeApp,
+			dockerApp: dockerApp,
+			auditApp:  auditApp,
+		}, nil
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &App{
+		storeApp:  storeApp,
+		dockerApp: dockerApp,
+		auditApp:  auditApp,
+		aead:      aead,
+	}, nil
+}
+
+func newAEAD(key string) (cipher.AEAD, error) {
+	hashedKey := sha256.Sum256([]byte(key))
+
+	block, err := aes.NewCipher(hashedKey[:])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return aead, nil
+}
+
+func encrypt(aead cipher.AEAD, value string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+func decrypt(aead cipher.AEAD, value string) (string, error) {
+	payload, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if len(payload) < aead.NonceSize() {
+		return "", errors.New("invalid encrypted secret")
+	}
+
+	content, err := aead.Open(nil, payload[:aead.NonceSize()], payload[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return string(content), nil
+}
+
+// Encrypt seals content with secrets key
+func (a *App) Encrypt(content []byte) ([]byte, error) {
+	if !a.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	encrypted, err := encrypt(a.aead, string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(encrypted), nil
+}
+
+// Decrypt opens content sealed with Encrypt
+func (a *App) Decrypt(content []byte) ([]byte, error) {
+	if !a.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	decrypted, err := decrypt(a.aead, string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(decrypted), nil
+}
+
+// Write marshals content as JSON and stores it encrypted for given name
+func (a *App) Write(name string, content interface{}) error {
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	encrypted, err := a.Encrypt(payload)
+	if err != nil {
+		return err
+	}
+
+	return a.storeApp.WriteFile(name, encrypted)
+}
+
+// Read decrypts content stored by Write for given name into output, untouched if not found
+func (a *App) Read(name string, output interface{}) error {
+	content, err := a.storeApp.ReadFile(name)
+	if err != nil || len(content) == 0 {
+		return err
+	}
+
+	decrypted, err := a.Decrypt(content)
+	if err != nil {
+		return err
+	}
+
+	return errors.WithStack(json.Unmarshal(decrypted, output))
+}
+
+func getStoreName(owner string, appName string) string {
+	return fmt.Sprintf("secrets/%s/%s.json", owner, appName)
+}
+
+// Enabled checks if secrets are usable
+func (a *App) Enabled() bool {
+	return a != nil && a.aead != nil
+}
+
+func (a *App) read(owner string, appName string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	if err := a.storeApp.Read(getStoreName(owner, appName), &secrets); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// Get retrieves decrypted value of an app's secret
+func (a *App) Get(owner string, appName string, name string) (string, error) {
+	if !a.Enabled() {
+		return "", ErrNotConfigured
+	}
+
+	secrets, err := a.read(owner, appName)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secrets[name]
+	if !ok {
+		return "", errors.New("secret %s not found for app %s", name, appName)
+	}
+
+	return decrypt(a.aead, value)
+}
+
+func (a *App) list(owner string, appName string) ([]string, error) {
+	secrets, err := a.read(owner, appName)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (a *App) set(owner string, appName string, name string, value string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	secrets, err := a.read(owner, appName)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encrypt(a.aead, value)
+	if err != nil {
+		return err
+	}
+
+	secrets[name] = encrypted
+	return a.storeApp.Write(getStoreName(owner, appName), secrets)
+}
+
+func (a *App) delete(owner string, appName string, name string) (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	secrets, err := a.read(owner, appName)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := secrets[name]; !ok {
+		return false, nil
+	}
+
+	delete(secrets, name)
+	return true, a.storeApp.Write(getStoreName(owner, appName), secrets)
+}
+
+// Move transfers secrets of an app from an owner to another
+func (a *App) Move(appName string, from string, to string) error {
+	if a == nil || !a.storeApp.Enabled() {
+		return nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.storeApp.Rename(getStoreName(from, appName), getStoreName(to, appName))
+}
+
+func (a *App) secretHandler(w http.ResponseWriter, r *http.Request, user *model.User, appName string, name string) {
+	if !a.dockerApp.Can(user, docker.DeployAction, appName) {
+		httperror.Forbidden(w)
+		return
+	}
+
+	if !validName.MatchString(name) {
+		httperror.BadRequest(w, errors.New("invalid secret name `%s`", name))
+		return
+	}
+
+	owner := a.dockerApp.GetAppOwner(user, appName)
+
+	switch r.Method {
+	case http.MethodPut:
+		value, err := request.ReadBodyRequest(r)
+		if err != nil {
+			httperror.BadRequest(w, err)
+			return
+		}
+
+		if len(value) == 0 {
+			httperror.BadRequest(w, errors.New("secret value is required"))
+			return
+		}
+
+		if err := a.set(owner, appName, name, string(value)); err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		deleted, err := a.delete(owner, appName, name)
+		if err != nil {
+			httperror.InternalServerError(w, err)
+			return
+		}
+
+		if !deleted {
+			httperror.NotFound(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Handler for request. Should be use with net/http
+func (a *App) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			httperror.BadRequest(w, errors.New("user not provided"))
+			return
+		}
+
+		if !a.Enabled() {
+			httperror.InternalServerError(w, ErrNotConfigured)
+			return
+		}
+
+		if appRequest.MatchString(r.URL.Path) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			appName := appRequest.FindStringSubmatch(r.URL.Path)[1]
+			if !a.dockerApp.Can(user, docker.GetAction, appName) {
+				httperror.Forbidden(w)
+				return
+			}
+
+			names, err := a.list(a.dockerApp.GetAppOwner(user, appName), appName)
+			if err != nil {
+				httperror.InternalServerError(w, err)
+				return
+			}
+
+			if err := httpjson.ResponseArrayJSON(w, http.StatusOK, names, httpjson.IsPretty(r)); err != nil {
+				httperror.InternalServerError(w, err)
+			}
+		} else if secretRequest.MatchString(r.URL.Path) {
+			matches := secretRequest.FindStringSubmatch(r.URL.Path)
+			appName, name := matches[1], matches[2]
+
+			action, ok := secretActions[r.Method]
+			if !ok {
+				a.secretHandler(w, r, user, appName, name)
+				return
+			}
+
+			entry := audit.Entry{User: user.Username, Action: action, App: appName, Params: map[string]string{"name": name}}
+			a.auditApp.RecordRequest(w, entry, func(writer http.ResponseWriter) {
+				a.secretHandler(writer, r, user, appName, name)
+			})
+		} else {
+			httperror.NotFound(w)
+		}
+	})
+}
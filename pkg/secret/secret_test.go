@@ -0,0 +1,144 @@
+package secret
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/docker"
+	"github.com/ViBiOh/dashboard/pkg/store"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	var cases = []struct {
+		intention string
+		value     string
+	}{
+		{
+			"should handle empty value",
+			"",
+		},
+		{
+			"should handle simple value",
+			"s3cr3t",
+		},
+		{
+			"should handle multiline value",
+			"-----BEGIN KEY-----\nabcdef\n-----END KEY-----\n",
+		},
+	}
+
+	aead, err := newAEAD("dashboard")
+	if err != nil {
+		t.Fatalf("newAEAD() = %v", err)
+	}
+
+	otherAead, err := newAEAD("other")
+	if err != nil {
+		t.Fatalf("newAEAD() = %v", err)
+	}
+
+	for _, testCase := range cases {
+		encrypted, err := encrypt(aead, testCase.value)
+		if err != nil {
+			t.Errorf("%s\nencrypt(%#v) = %v", testCase.intention, testCase.value, err)
+			continue
+		}
+
+		if testCase.value != "" && encrypted == testCase.value {
+			t.Errorf("%s\nencrypt(%#v) = %#v, want encrypted value", testCase.intention, testCase.value, encrypted)
+		}
+
+		if result, err := decrypt(aead, encrypted); err != nil || result != testCase.value {
+			t.Errorf("%s\ndecrypt(%#v) = (%#v, %v), want (%#v, nil)", testCase.intention, encrypted, result, err, testCase.value)
+		}
+
+		if _, err := decrypt(otherAead, encrypted); err == nil {
+			t.Errorf("%s\ndecrypt(%#v) with other key = nil, want error", testCase.intention, encrypted)
+		}
+	}
+}
+
+func TestSecretHandlerOwner(t *testing.T) {
+	directory, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	storeConfig := store.Flags(fs, "store")
+	dockerConfig := docker.Flags(fs, "docker")
+	secretConfig := Flags(fs, "secrets")
+	if err := fs.Parse([]string{"-storeDirectory", directory, "-secretsKey", "dashboard"}); err != nil {
+		t.Fatal(err)
+	}
+
+	storeApp, err := store.New(storeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storeApp.Write("teams.json", map[string][]string{"web": {"alice", "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	dockerApp, err := docker.New(dockerConfig, storeApp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dockerApp.SetAppOwnership("blog", docker.Ownership{Owner: "alice", Team: "web"}); err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := New(secretConfig, storeApp, dockerApp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = []struct {
+		intention string
+		user      *model.User
+		value     string
+		owner     string
+	}{
+		{
+			"should store secret of owner",
+			model.NewUser("1", "alice", "", ""),
+			"from-alice",
+			"alice",
+		},
+		{
+			"should store secret of member under owner",
+			model.NewUser("2", "bob", "", ""),
+			"from-bob",
+			"alice",
+		},
+		{
+			"should store secret of user out of team under its name",
+			model.NewUser("3", "carol", "", ""),
+			"from-carol",
+			"carol",
+		},
+	}
+
+	for _, testCase := range cases {
+		writer := httptest.NewRecorder()
+		app.secretHandler(writer, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(testCase.value)), testCase.user, "blog", "DB_PASSWORD")
+
+		if writer.Code != http.StatusNoContent {
+			t.Errorf("%s\nsecretHandler() = %d, want %d", testCase.intention, writer.Code, http.StatusNoContent)
+			continue
+		}
+
+		if result, err := app.Get(testCase.owner, "blog", "DB_PASSWORD"); err != nil || result != testCase.value {
+			t.Errorf("%s\nGet(%#v) = (%#v, %v), want (%#v, nil)", testCase.intention, testCase.owner, result, err, testCase.value)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ViBiOh/httputils/pkg/errors"
+	"github.com/ViBiOh/httputils/pkg/logger"
+	"github.com/ViBiOh/httputils/pkg/tools"
+)
+
+// ErrNotConfigured occurs when store is used without directory
+var ErrNotConfigured = errors.New("no store directory configured")
+
+// Config of package
+type Config struct {
+	directory *string
+}
+
+// App of package
+type App struct {
+	directory string
+	mutex     sync.RWMutex
+}
+
+// Flags adds flags for configuring package
+func Flags(fs *flag.FlagSet, prefix string) Config {
+	return Config{
+		directory: fs.String(tools.ToCamel(fmt.Sprintf("%sDirectory", prefix)), "", "[store] Directory for persisting server-side data"),
+	}
+}
+
+// New creates new App from Config
+func New(config Config) (*App, error) {
+	directory := strings.TrimSpace(*config.directory)
+
+	if directory == "" {
+		logger.Warn("no store directory provided, server-side data will not be persisted")
+		return &App{}, nil
+	}
+
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &App{
+		directory: directory,
+	}, nil
+}
+
+// Enabled checks if store is usable
+func (a *App) Enabled() bool {
+	return a != nil && a.directory != ""
+}
+
+func (a *App) getPath(name string) (string, error) {
+	if !a.Enabled() {
+		return "", ErrNotConfigured
+	}
+
+	path := filepath.Join(a.directory, filepath.Clean(fmt.Sprintf("/%s", name)))
+	if path == a.directory {
+		return "", errors.New("invalid store name %s", name)
+	}
+
+	return path, nil
+}
+
+// ReadFile reads raw content of given name, nil if not found
+func (a *App) ReadFile(name string) ([]byte, error) {
+	if !a.Enabled() {
+		return nil, nil
+	}
+
+	path, err := a.getPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return content, errors.WithStack(err)
+}
+
+// WriteFile writes raw content for given name, atomically
+func (a *App) WriteFile(name string, content []byte) error {
+	path, err := a.getPath(name)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmpPath := fmt.Sprintf("%s.tmp", path)
+	if err := ioutil.WriteFile(tmpPath, content, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(tmpPath, path))
+}
+
+// DeleteFile removes content of given name, if present
+func (a *App) DeleteFile(name string) error {
+	path, err := a.getPath(name)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Rename moves content of given name to a new name, overwriting it, nothing being done if not found
+func (a *App) Rename(name string, newName string) error {
+	path, err := a.getPath(name)
+	if err != nil {
+		return err
+	}
+
+	newPath, err := a.getPath(newName)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(path, newPath))
+}
+
+// Read unmarshals JSON content of given name into output, untouched if not found
+func (a *App) Read(name string, output interface{}) error {
+	content, err := a.ReadFile(name)
+	if err != nil || len(content) == 0 {
+		return err
+	}
+
+	return errors.WithStack(json.Unmarshal(content, output))
+}
+
+// Write marshals content as JSON for given name
+func (a *App) Write(name string, content interface{}) error {
+	payload, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return a.WriteFile(name, payload)
+}
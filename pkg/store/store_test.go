@@ -0,0 +1,277 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestApp(t *testing.T) (*App, func()) {
+	directory, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &App{directory: directory}, func() {
+		os.RemoveAll(directory)
+	}
+}
+
+func TestGetPath(t *testing.T) {
+	app := &App{directory: "/srv/dashboard"}
+
+	var cases = []struct {
+		intention string
+		app       *App
+		name      string
+		want      string
+		wantErr   bool
+	}{
+		{
+			"should fail without directory",
+			&App{},
+			"owners.json",
+			"",
+			true,
+		},
+		{
+			"should join name to directory",
+			app,
+			"composes/bob/blog.yml",
+			"/srv/dashboard/composes/bob/blog.yml",
+			false,
+		},
+		{
+			"should not escape directory",
+			app,
+			"../../etc/passwd",
+			"/srv/dashboard/etc/passwd",
+			false,
+		},
+		{
+			"should not escape directory with absolute name",
+			app,
+			"/etc/passwd",
+			"/srv/dashboard/etc/passwd",
+			false,
+		},
+		{
+			"should refuse empty name",
+			app,
+			"",
+			"",
+			true,
+		},
+		{
+			"should refuse name resolving to directory",
+			app,
+			"composes/..",
+			"",
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		result, err := testCase.app.getPath(testCase.name)
+
+		failed := false
+
+		if testCase.wantErr && err == nil {
+			failed = true
+		} else if !testCase.wantErr && err != nil {
+			failed = true
+		} else if result != testCase.want {
+			failed = true
+		}
+
+		if failed {
+			t.Errorf("%s\ngetPath(%#v) = (%#v, %v), want (%#v, error %t)", testCase.intention, testCase.name, result, err, testCase.want, testCase.wantErr)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	app := &App{}
+
+	if content, err := app.ReadFile("owners.json"); content != nil || err != nil {
+		t.Errorf("ReadFile() = (%#v, %v), want (nil, nil)", content, err)
+	}
+
+	output := map[string]string{"blog": "bob"}
+	if err := app.Read("owners.json", &output); err != nil || !reflect.DeepEqual(output, map[string]string{"blog": "bob"}) {
+		t.Errorf("Read() = (%+v, %v), want untouched output", output, err)
+	}
+
+	if err := app.WriteFile("owners.json", []byte("{}")); err != ErrNotConfigured {
+		t.Errorf("WriteFile() = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if err := app.DeleteFile("owners.json"); err != ErrNotConfigured {
+		t.Errorf("DeleteFile() = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if err := app.Rename("owners.json", "teams.json"); err != ErrNotConfigured {
+		t.Errorf("Rename() = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	app, clean := newTestApp(t)
+	defer clean()
+
+	var cases = []struct {
+		intention string
+		name      string
+		content   map[string]string
+	}{
+		{
+			"should write at root",
+			"owners.json",
+			map[string]string{"blog": "bob"},
+		},
+		{
+			"should create missing directories",
+			"variables/bob/blog.json",
+			map[string]string{"DOMAIN": "vibioh.fr"},
+		},
+		{
+			"should overwrite existing content",
+			"owners.json",
+			map[string]string{"blog": "alice"},
+		},
+	}
+
+	for _, testCase := range cases {
+		if err := app.Write(testCase.name, testCase.content); err != nil {
+			t.Errorf("%s\nWrite(%#v) = %v", testCase.intention, testCase.name, err)
+			continue
+		}
+
+		result := make(map[string]string)
+		if err := app.Read(testCase.name, &result); err != nil || !reflect.DeepEqual(result, testCase.content) {
+			t.Errorf("%s\nRead(%#v) = (%+v, %v), want (%+v, nil)", testCase.intention, testCase.name, result, err, testCase.content)
+		}
+
+		if _, err := os.Stat(filepath.Join(app.directory, testCase.name+".tmp")); !os.IsNotExist(err) {
+			t.Errorf("%s\nWrite(%#v) left temporary file", testCase.intention, testCase.name)
+		}
+	}
+
+	if content, err := app.ReadFile("unknown.json"); content != nil || err != nil {
+		t.Errorf("ReadFile(unknown) = (%#v, %v), want (nil, nil)", content, err)
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	app, clean := newTestApp(t)
+	defer clean()
+
+	if err := app.WriteFile("envs/bob/blog.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = []struct {
+		intention string
+		name      string
+	}{
+		{
+			"should delete existing file",
+			"envs/bob/blog.json",
+		},
+		{
+			"should ignore missing file",
+			"envs/bob/blog.json",
+		},
+	}
+
+	for _, testCase := range cases {
+		if err := app.DeleteFile(testCase.name); err != nil {
+			t.Errorf("%s\nDeleteFile(%#v) = %v, want nil", testCase.intention, testCase.name, err)
+		}
+
+		if content, err := app.ReadFile(testCase.name); content != nil || err != nil {
+			t.Errorf("%s\nReadFile(%#v) = (%#v, %v), want (nil, nil)", testCase.intention, testCase.name, content, err)
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	var cases = []struct {
+		intention string
+		files     map[string]string
+		name      string
+		newName   string
+		want      map[string]string
+		wantErr   bool
+	}{
+		{
+			"should do nothing if not found",
+			map[string]string{},
+			"composes/alice/blog.yml",
+			"composes/bob/blog.yml",
+			map[string]string{},
+			false,
+		},
+		{
+			"should move to new directory",
+			map[string]string{"composes/alice/blog.yml": "version: '2'"},
+			"composes/alice/blog.yml",
+			"composes/bob/blog.yml",
+			map[string]string{"composes/bob/blog.yml": "version: '2'"},
+			false,
+		},
+		{
+			"should overwrite existing content",
+			map[string]string{"composes/alice/blog.yml": "version: '2'", "composes/bob/blog.yml": "version: '3'"},
+			"composes/alice/blog.yml",
+			"composes/bob/blog.yml",
+			map[string]string{"composes/bob/blog.yml": "version: '2'"},
+			false,
+		},
+		{
+			"should fail on invalid new name",
+			map[string]string{"composes/alice/blog.yml": "version: '2'"},
+			"composes/alice/blog.yml",
+			"",
+			map[string]string{"composes/alice/blog.yml": "version: '2'"},
+			true,
+		},
+	}
+
+	for _, testCase := range cases {
+		app, clean := newTestApp(t)
+
+		for name, content := range testCase.files {
+			if err := app.WriteFile(name, []byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := app.Rename(testCase.name, testCase.newName)
+
+		result := make(map[string]string)
+		for _, name := range []string{testCase.name, testCase.newName} {
+			if content, _ := app.ReadFile(name); content != nil {
+				result[name] = string(content)
+			}
+		}
+
+		clean()
+
+		failed := false
+
+		if testCase.wantErr && err == nil {
+			failed = true
+		} else if !testCase.wantErr && err != nil {
+			failed = true
+		} else if !reflect.DeepEqual(result, testCase.want) {
+			failed = true
+		}
+
+		if failed {
+			t.Errorf("%s\nRename(%#v, %#v) = (%+v, %v), want (%+v, error %t)", testCase.intention, testCase.name, testCase.newName, result, err, testCase.want, testCase.wantErr)
+		}
+	}
+}
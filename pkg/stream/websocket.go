@@ -12,6 +12,7 @@ import (
 
 	"github.com/ViBiOh/auth/pkg/auth"
 	"github.com/ViBiOh/auth/pkg/model"
+	"github.com/ViBiOh/dashboard/pkg/audit"
 	"github.com/ViBiOh/dashboard/pkg/commons"
 	"github.com/ViBiOh/dashboard/pkg/docker"
 	"github.com/ViBiOh/httputils/pkg/errors"
@@ -50,6 +51,7 @@ type Config struct {
 type App struct {
 	authApp    *auth.App
 	dockerApp  *docker.App
+	auditApp   *audit.App
 	wsUpgrader websocket.Upgrader
 }
 
@@ -61,7 +63,7 @@ func Flags(fs *flag.FlagSet, prefix string) Config {
 }
 
 // New creates new App from Config
-func New(config Config, authApp *auth.App, dockerApp *docker.App) (*App, error) {
+func New(config Config, authApp *auth.App, dockerApp *docker.App, auditApp *audit.App) (*App, error) {
 	hostCheck, err := regexp.Compile(*config.websocketOrigin)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -70,6 +72,7 @@ func New(config Config, authApp *auth.App, dockerApp *docker.App) (*App, error)
 	return &App{
 		authApp:   authApp,
 		dockerApp: dockerApp,
+		auditApp:  auditApp,
 		wsUpgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -138,11 +141,39 @@ func readContent(user *model.User, ws *websocket.Conn, name string, done chan<-
 	}
 }
 
+func (a *App) isDemandAllowed(ctx context.Context, user *model.User, containerID, action string) bool {
+	allowed, container, err := a.dockerApp.IsAllowed(ctx, user, containerID, action)
+
+	entry := audit.NewEntry(user, action, err)
+	entry.Container = containerID
+	if container != nil && container.Config != nil {
+		entry.App = container.Config.Labels[commons.AppLabel]
+	}
+
+	if err != nil {
+		a.auditApp.Record(entry)
+		logger.Error("%+v", err)
+		return false
+	}
+
+	if !allowed {
+		entry.Result = audit.Denied
+		logger.Warn("user=%s is not allowed to %s container=%s", user.Username, action, containerID)
+	}
+
+	a.auditApp.Record(entry)
+	return allowed
+}
+
 func (a *App) streamEvents(ctx context.Context, cancel context.CancelFunc, user *model.User, _ string, output chan<- []byte) {
 	defer cancel()
 
+	if !a.dockerApp.Can(user, docker.GetAction, "") {
+		logger.Warn("user=%s is not allowed to %s events", user.Username, docker.GetAction)
+		return
+	}
+
 	filtersArgs := filters.NewArgs()
-	docker.LabelFilters(user, &filtersArgs, "")
 	commons.EventFilters(&filtersArgs)
 
 	messages, errs := a.dockerApp.Docker.Events(ctx, types.EventsOptions{Filters: filtersArgs})
@@ -153,6 +184,10 @@ func (a *App) streamEvents(ctx context.Context, cancel context.CancelFunc, user
 			return
 
 		case message := <-messages:
+			if !a.dockerApp.IsOwner(user, message.Actor.Attributes) || !a.dockerApp.Can(user, docker.GetAction, message.Actor.Attributes[commons.AppLabel]) {
+				continue
+			}
+
 			messageJSON, err := json.Marshal(message)
 			if err != nil {
 				logger.Error("%+v", errors.WithStack(err))
@@ -171,6 +206,11 @@ func (a *App) streamEvents(ctx context.Context, cancel context.CancelFunc, user
 }
 
 func (a *App) streamLogs(ctx context.Context, cancel context.CancelFunc, user *model.User, containerID string, output chan<- []byte) {
+	if !a.isDemandAllowed(ctx, user, containerID, docker.LogsAction) {
+		cancel()
+		return
+	}
+
 	logs, err := a.dockerApp.Docker.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Follow: true, Tail: tailSize})
 	defer cancel()
 
@@ -197,6 +237,11 @@ func (a *App) streamLogs(ctx context.Context, cancel context.CancelFunc, user *m
 }
 
 func (a *App) streamStats(ctx context.Context, cancel context.CancelFunc, user *model.User, containerID string, output chan<- []byte) {
+	if !a.isDemandAllowed(ctx, user, containerID, docker.StatsAction) {
+		cancel()
+		return
+	}
+
 	stats, err := a.dockerApp.Docker.ContainerStats(ctx, containerID, true)
 	defer cancel()
 